@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/handler"
+	"github.com/sebasukodo/chirpy/internal/importer"
+)
+
+func runCommand(cfg *handler.ApiConfig, name string, args []string) error {
+
+	switch name {
+	case "import":
+		return importCommand(cfg, args)
+	case "replay-webhooks":
+		return replayWebhooksCommand(cfg, args)
+	case "worker":
+		return workerCommand(cfg, args)
+	}
+
+	return fmt.Errorf("unknown command %q", name)
+
+}
+
+func importCommand(cfg *handler.ApiConfig, args []string) error {
+
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	email := flags.String("email", "", "e-mail of the account to import into")
+	policy := flags.String("long-body-policy", importer.PolicyReject, "what to do with posts over the chirp length limit: reject, truncate, split or skip")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: chirpy import -email <email> [-long-body-policy <policy>] <archive>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *email == "" || flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if !importer.ValidPolicy(*policy) {
+		return fmt.Errorf("invalid long body policy %q", *policy)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	user, err := cfg.DbQueries.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("could not find user %s: %w", *email, err)
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	source, items, err := importer.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	imp, err := cfg.StartImport(ctx, user.ID, source, *policy, items, false)
+	if err != nil {
+		return fmt.Errorf("could not start import: %w", err)
+	}
+
+	fmt.Printf("importing %d %s posts into %s (import %v)\n", len(items), source, user.Email, imp.ID)
+
+	progress, err := cfg.RunImport(ctx, imp, items, func(progress importer.Progress, itemErr *importer.ItemError) {
+		if itemErr != nil {
+			fmt.Printf("  item %s: %s\n", itemErr.Ref, itemErr.Message)
+		}
+		if progress.Processed%100 == 0 {
+			fmt.Printf("  %d/%d processed\n", progress.Processed, progress.Total)
+		}
+	})
+
+	if err != nil {
+		if err := cfg.CancelImport(context.Background(), imp.ID); err != nil {
+			return fmt.Errorf("could not cancel import: %w", err)
+		}
+		fmt.Printf("cancelled after %d of %d\n", progress.Processed, progress.Total)
+		return nil
+	}
+
+	fmt.Printf("done: %d imported, %d skipped, %d failed of %d\n", progress.Imported, progress.Skipped, progress.Failed, progress.Total)
+
+	return nil
+
+}
+
+func replayWebhooksCommand(cfg *handler.ApiConfig, args []string) error {
+
+	flags := flag.NewFlagSet("replay-webhooks", flag.ExitOnError)
+	failed := flags.Bool("failed", false, "replay every failed webhook event")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: chirpy replay-webhooks (-failed | <event id>...)")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *failed == (flags.NArg() > 0) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ids := []uuid.UUID{}
+	if *failed {
+		events, err := cfg.DbQueries.GetFailedWebhookEvents(ctx)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+	}
+
+	for _, arg := range flags.Args() {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return fmt.Errorf("invalid event id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	stillFailing := 0
+	for _, id := range ids {
+		event, err := cfg.ReplayWebhookEvent(ctx, id)
+		if err != nil {
+			stillFailing++
+			fmt.Printf("  %v: %v\n", id, err)
+			continue
+		}
+		fmt.Printf("  %v: %s %s\n", id, event.EventType, event.Status)
+	}
+
+	fmt.Printf("done: replayed %d events, %d failed\n", len(ids), stillFailing)
+
+	return nil
+
+}
+
+// workerCommand runs background jobs without serving HTTP, for setups where
+// the servers run with JOB_WORKERS=0.
+func workerCommand(cfg *handler.ApiConfig, args []string) error {
+
+	flags := flag.NewFlagSet("worker", flag.ExitOnError)
+	workers := flags.Int("workers", 4, "number of jobs to run at the same time")
+	flags.Parse(args)
+
+	if *workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("running %d workers\n", *workers)
+	cfg.Jobs.Run(ctx, *workers)
+	fmt.Println("stopped")
+
+	return nil
+
+}
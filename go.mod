@@ -9,9 +9,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.14.0
 )
 
-require (
-	golang.org/x/crypto v0.14.0 // indirect
-	golang.org/x/sys v0.34.0 // indirect
-)
+require golang.org/x/sys v0.34.0 // indirect
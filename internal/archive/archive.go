@@ -0,0 +1,247 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const FormatVersion = "chirpy-export/1"
+
+// MaxFileSize caps the uncompressed size of a single file read from an
+// archive, so a small zip cannot expand into gigabytes.
+const MaxFileSize = 256 << 20
+
+var ErrFileTooLarge = fmt.Errorf("file is larger than %d bytes", MaxFileSize)
+
+const (
+	fileManifest = "manifest.json"
+	fileProfile  = "profile.json"
+	fileChirps   = "chirps.json"
+	fileChirpsUI = "chirps.html"
+	fileSessions = "sessions.json"
+	fileHistory  = "account_history.json"
+)
+
+type Manifest struct {
+	Format     string    `json:"format"`
+	ExportedAt time.Time `json:"exported_at"`
+	UserID     uuid.UUID `json:"user_id"`
+}
+
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+type Chirp struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Body       string    `json:"body"`
+	Visibility string    `json:"visibility,omitempty"`
+}
+
+type Session struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type AccountEvent struct {
+	CreatedAt time.Time `json:"created_at"`
+	Event     string    `json:"event"`
+	Detail    string    `json:"detail"`
+}
+
+type Archive struct {
+	Manifest Manifest
+	Profile  Profile
+	Chirps   []Chirp
+	Sessions []Session
+	History  []AccountEvent
+}
+
+var chirpsPage = template.Must(template.New("chirps").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>Chirps of {{.Profile.Email}}</title>
+</head>
+<body>
+<h1>Chirps of {{.Profile.Email}}</h1>
+<p>Exported on {{.Manifest.ExportedAt.Format "January 2, 2006 15:04 MST"}}</p>
+{{range .Chirps}}<article>
+<p>{{.Body}}</p>
+<time datetime="{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.Format "January 2, 2006 15:04"}}</time>
+</article>
+{{else}}<p>No chirps yet.</p>
+{{end}}</body>
+</html>
+`))
+
+func Write(w io.Writer, a Archive) error {
+
+	zw := zip.NewWriter(w)
+
+	a.Manifest.Format = FormatVersion
+
+	jsonFiles := []struct {
+		name string
+		data any
+	}{
+		{fileManifest, a.Manifest},
+		{fileProfile, a.Profile},
+		{fileChirps, nonNil(a.Chirps)},
+		{fileSessions, nonNil(a.Sessions)},
+		{fileHistory, nonNil(a.History)},
+	}
+
+	for _, file := range jsonFiles {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return fmt.Errorf("could not add %s: %w", file.name, err)
+		}
+
+		encoder := json.NewEncoder(fw)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return fmt.Errorf("could not write %s: %w", file.name, err)
+		}
+	}
+
+	fw, err := zw.Create(fileChirpsUI)
+	if err != nil {
+		return fmt.Errorf("could not add %s: %w", fileChirpsUI, err)
+	}
+
+	if err := chirpsPage.Execute(fw, a); err != nil {
+		return fmt.Errorf("could not write %s: %w", fileChirpsUI, err)
+	}
+
+	return zw.Close()
+
+}
+
+func Read(r io.ReaderAt, size int64) (Archive, error) {
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Archive{}, fmt.Errorf("not a zip archive: %w", err)
+	}
+
+	a := Archive{}
+
+	if err := readJSON(zr, fileManifest, &a.Manifest, true); err != nil {
+		return Archive{}, err
+	}
+
+	if a.Manifest.Format != FormatVersion {
+		return Archive{}, fmt.Errorf("unsupported archive format %q", a.Manifest.Format)
+	}
+
+	files := []struct {
+		name string
+		dest any
+	}{
+		{fileProfile, &a.Profile},
+		{fileChirps, &a.Chirps},
+		{fileSessions, &a.Sessions},
+		{fileHistory, &a.History},
+	}
+
+	for _, file := range files {
+		if err := readJSON(zr, file.name, file.dest, false); err != nil {
+			return Archive{}, err
+		}
+	}
+
+	return a, nil
+
+}
+
+func readJSON(zr *zip.Reader, name string, dest any, required bool) error {
+
+	var entry *zip.File
+	for _, file := range zr.File {
+		if file.Name == name {
+			entry = file
+			break
+		}
+	}
+	if entry == nil {
+		if required {
+			return fmt.Errorf("archive is missing %s", name)
+		}
+		return nil
+	}
+
+	file, err := Open(entry)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(dest); err != nil {
+		return fmt.Errorf("could not decode %s: %w", name, err)
+	}
+
+	return nil
+
+}
+
+// Open opens a file of a zip archive and fails once more than MaxFileSize
+// bytes are read from it. The size in the header is checked up front, but it
+// is not trusted since it can be forged.
+func Open(file *zip.File) (io.ReadCloser, error) {
+
+	if file.UncompressedSize64 > MaxFileSize {
+		return nil, fmt.Errorf("%s: %w", file.Name, ErrFileTooLarge)
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", file.Name, err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{LimitReader(rc, MaxFileSize), rc}, nil
+
+}
+
+// LimitReader reads at most n bytes from r and returns ErrFileTooLarge if r
+// has more. Unlike io.LimitReader it does not pass a cut off file as complete.
+func LimitReader(r io.Reader, n int64) io.Reader {
+	return &limitedReader{r: io.LimitReader(r, n+1), left: n}
+}
+
+type limitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n + int(l.left), ErrFileTooLarge
+	}
+	return n, err
+}
+
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
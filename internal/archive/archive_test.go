@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWriteRead(t *testing.T) {
+
+	userID := uuid.New()
+	created := time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC)
+
+	original := Archive{
+		Manifest: Manifest{ExportedAt: created.Add(time.Hour), UserID: userID},
+		Profile:  Profile{ID: userID, CreatedAt: created, UpdatedAt: created, Email: "ann@example.com"},
+		Chirps: []Chirp{
+			{ID: uuid.New(), CreatedAt: created, UpdatedAt: created, Body: "<b>hello</b> world"},
+		},
+		History: []AccountEvent{{CreatedAt: created, Event: "registered"}},
+	}
+
+	buf := bytes.Buffer{}
+	if err := Write(&buf, original); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	restored, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if restored.Manifest.Format != FormatVersion || restored.Manifest.UserID != userID {
+		t.Errorf("unexpected manifest: %+v", restored.Manifest)
+	}
+
+	if restored.Profile.Email != original.Profile.Email {
+		t.Errorf("expected email %q, got %q", original.Profile.Email, restored.Profile.Email)
+	}
+
+	if len(restored.Chirps) != 1 || restored.Chirps[0].Body != original.Chirps[0].Body || !restored.Chirps[0].CreatedAt.Equal(created) {
+		t.Errorf("unexpected chirps: %+v", restored.Chirps)
+	}
+
+	if restored.Sessions == nil || len(restored.Sessions) != 0 {
+		t.Errorf("expected empty sessions, got %+v", restored.Sessions)
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("not a zip")), 9); err == nil {
+		t.Errorf("expected error for invalid archive")
+	}
+
+}
+
+func TestLimitReader(t *testing.T) {
+
+	data, err := io.ReadAll(LimitReader(strings.NewReader("hello"), 5))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("expected the whole file within the limit, got %q, %v", data, err)
+	}
+
+	data, err = io.ReadAll(LimitReader(strings.NewReader("hello world"), 5))
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge, got %v", err)
+	}
+	if len(data) > 5 {
+		t.Errorf("expected at most 5 bytes, got %d", len(data))
+	}
+
+}
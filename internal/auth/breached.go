@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const breachedPrefixLength = 5
+
+// BreachedPasswords holds SHA-1 hashes of known breached passwords, bucketed
+// by the first five hex characters like the Pwned Passwords range API.
+type BreachedPasswords struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedPasswords reads a corpus with one SHA-1 hash per line. The
+// "HASH:COUNT" format of the Pwned Passwords downloads is accepted as well.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached password corpus: %w", err)
+	}
+	defer file.Close()
+
+	breached := &BreachedPasswords{ranges: map[string][]string{}}
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid hash on line %d of breached password corpus", lineNumber)
+		}
+
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("invalid hash on line %d of breached password corpus", lineNumber)
+		}
+
+		prefix := hash[:breachedPrefixLength]
+		breached.ranges[prefix] = append(breached.ranges[prefix], hash[breachedPrefixLength:])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read breached password corpus: %w", err)
+	}
+
+	for prefix := range breached.ranges {
+		sort.Strings(breached.ranges[prefix])
+	}
+
+	return breached, nil
+
+}
+
+// Range returns every known hash suffix sharing the given five character prefix.
+func (b *BreachedPasswords) Range(prefix string) []string {
+	return b.ranges[strings.ToUpper(prefix)]
+}
+
+// Contains only ever looks up the hash prefix, so the corpus can be swapped
+// for a remote range API without changing callers.
+func (b *BreachedPasswords) Contains(password string) bool {
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := b.Range(hash[:breachedPrefixLength])
+	suffix := hash[breachedPrefixLength:]
+
+	i := sort.SearchStrings(suffixes, suffix)
+
+	return i < len(suffixes) && suffixes[i] == suffix
+
+}
+
+func (b *BreachedPasswords) Len() int {
+
+	count := 0
+	for _, suffixes := range b.ranges {
+		count += len(suffixes)
+	}
+
+	return count
+
+}
@@ -3,13 +3,27 @@ package auth
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/alexedwards/argon2id"
+	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+type HashStatus string
+
+const (
+	HashCurrent  HashStatus = "current"
+	HashOutdated HashStatus = "outdated"
+	HashLegacy   HashStatus = "legacy"
+	HashUnset    HashStatus = "unset"
+)
+
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+func HashPassword(password string, params *argon2id.Params) (string, error) {
+	hash, err := argon2id.CreateHash(password, params)
 	if err != nil {
 		return "", fmt.Errorf("could not hash password: %w", err)
 	}
@@ -24,11 +38,65 @@ func HashToken(token string) string {
 
 func CheckPasswordHash(password string, hash string) (bool, error) {
 
-	matching, err := argon2id.ComparePasswordAndHash(password, hash)
-	if err != nil {
-		return false, err
+	switch {
+	case isArgon2idHash(hash):
+		matching, err := argon2id.ComparePasswordAndHash(password, hash)
+		if err != nil {
+			return false, err
+		}
+		return matching, nil
+
+	case isBcryptHash(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
 	}
 
-	return matching, nil
+	return false, ErrUnsupportedHash
+
+}
+
+// GetHashStatus compares a stored hash against the currently configured
+// parameters. Anything that is not argon2id or bcrypt, like the 'unset'
+// placeholder from the users auth migration, can never be verified.
+func GetHashStatus(hash string, params *argon2id.Params) HashStatus {
+
+	switch {
+	case isArgon2idHash(hash):
+		stored, _, _, err := argon2id.DecodeHash(hash)
+		if err != nil {
+			return HashUnset
+		}
+		if stored.Memory < params.Memory ||
+			stored.Iterations < params.Iterations ||
+			stored.Parallelism < params.Parallelism ||
+			stored.SaltLength < params.SaltLength ||
+			stored.KeyLength < params.KeyLength {
+			return HashOutdated
+		}
+		return HashCurrent
+
+	case isBcryptHash(hash):
+		return HashLegacy
+	}
+
+	return HashUnset
+
+}
+
+func NeedsRehash(hash string, params *argon2id.Params) bool {
+	return GetHashStatus(hash, params) != HashCurrent
+}
+
+func isArgon2idHash(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
 
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
 }
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/alexedwards/argon2id"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashStatus(t *testing.T) {
+
+	weak := &argon2id.Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	strong := &argon2id.Params{Memory: 16 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	weakHash, err := HashPassword("correct horse", weak)
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	strongHash, err := HashPassword("correct horse", strong)
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt failed: %v", err)
+	}
+
+	type testCase struct {
+		name   string
+		hash   string
+		status HashStatus
+		match  bool
+	}
+
+	runCases := []testCase{
+		{name: "current argon2id", hash: strongHash, status: HashCurrent, match: true},
+		{name: "weaker argon2id", hash: weakHash, status: HashOutdated, match: true},
+		{name: "bcrypt", hash: string(legacyHash), status: HashLegacy, match: true},
+		{name: "unset placeholder", hash: "unset", status: HashUnset, match: false},
+	}
+
+	for _, test := range runCases {
+		if status := GetHashStatus(test.hash, strong); status != test.status {
+			t.Errorf("%s: expected status %q, got %q", test.name, test.status, status)
+		}
+
+		match, _ := CheckPasswordHash("correct horse", test.hash)
+		if match != test.match {
+			t.Errorf("%s: expected match %v, got %v", test.name, test.match, match)
+		}
+
+		if match, _ := CheckPasswordHash("wrong horse", test.hash); match {
+			t.Errorf("%s: wrong password matched", test.name)
+		}
+	}
+
+	parallel := *strong
+	parallel.Parallelism = 2
+	if status := GetHashStatus(strongHash, &parallel); status != HashOutdated {
+		t.Errorf("more parallelism: expected status %q, got %q", HashOutdated, status)
+	}
+
+}
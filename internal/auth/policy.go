@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"fmt"
+)
+
+const (
+	DefaultPasswordMinLength   = 8
+	DefaultPasswordMinStrength = 3
+)
+
+type PasswordPolicy struct {
+	MinLength   int
+	MinStrength int
+	Breached    *BreachedPasswords
+}
+
+func (p PasswordPolicy) Validate(password string, userInputs ...string) error {
+
+	if password == "" {
+		return fmt.Errorf("Password is required")
+	}
+
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+
+	if EstimateStrength(password, userInputs...) < p.MinStrength {
+		return fmt.Errorf("Password is too easy to guess")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		return fmt.Errorf("Password has appeared in a data breach, please choose another one")
+	}
+
+	return nil
+
+}
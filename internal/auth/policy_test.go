@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+
+	corpus := filepath.Join(t.TempDir(), "breached.txt")
+	sum := sha1.Sum([]byte("Xk9#vLq2!mZt"))
+	content := "# test corpus\n" + strings.ToUpper(hex.EncodeToString(sum[:])) + ":42\n"
+	if err := os.WriteFile(corpus, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not write corpus: %v", err)
+	}
+
+	breached, err := LoadBreachedPasswords(corpus)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords failed: %v", err)
+	}
+
+	policy := PasswordPolicy{
+		MinLength:   DefaultPasswordMinLength,
+		MinStrength: DefaultPasswordMinStrength,
+		Breached:    breached,
+	}
+
+	type testCase struct {
+		name        string
+		password    string
+		userInputs  []string
+		expectError bool
+	}
+
+	runCases := []testCase{
+		{name: "empty", password: "", expectError: true},
+		{name: "too short", password: "a8#Kq", expectError: true},
+		{name: "common word", password: "password", expectError: true},
+		{name: "leet common word", password: "P4ssw0rd", expectError: true},
+		{name: "keyboard walk", password: "qwertyuiop", expectError: true},
+		{name: "sequence and year", password: "abcdef2024", expectError: true},
+		{name: "contains email", password: "jane.doe.jane", userInputs: []string{"jane.doe@example.com"}, expectError: true},
+		{name: "breached", password: "Xk9#vLq2!mZt", expectError: true},
+		{name: "strong", password: "tangerine-gravel-oboe", expectError: false},
+		{name: "random", password: "f7#Qw9zLm2", expectError: false},
+	}
+
+	for _, test := range runCases {
+		err := policy.Validate(test.password, test.userInputs...)
+		if (err != nil) != test.expectError {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.expectError, err)
+		}
+	}
+
+}
+
+func TestBreachedPasswordsRange(t *testing.T) {
+
+	corpus := filepath.Join(t.TempDir(), "breached.txt")
+	content := "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\nnot-a-hash\n"
+	if err := os.WriteFile(corpus, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not write corpus: %v", err)
+	}
+
+	if _, err := LoadBreachedPasswords(corpus); err == nil {
+		t.Errorf("expected invalid corpus line to be rejected")
+	}
+
+	content = "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:3861493\n"
+	if err := os.WriteFile(corpus, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not write corpus: %v", err)
+	}
+
+	breached, err := LoadBreachedPasswords(corpus)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords failed: %v", err)
+	}
+
+	suffixes := breached.Range("5baa6")
+	if len(suffixes) != 1 || suffixes[0] != "1E4C9B93F3F0682250B6CF8331B7EE68FD8" {
+		t.Errorf("unexpected range result: %v", suffixes)
+	}
+
+	if !breached.Contains("password") {
+		t.Errorf("expected \"password\" to be found in the corpus")
+	}
+
+	if breached.Contains("Password") {
+		t.Errorf("did not expect \"Password\" to be found in the corpus")
+	}
+
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Small zxcvbn-style dictionary. Matches are cheap to guess no matter how
+// long they are, so they only add a few bits to the estimate.
+var commonWords = []string{
+	"password", "passwort", "qwerty", "letmein", "welcome", "admin", "iloveyou",
+	"monkey", "dragon", "football", "baseball", "soccer", "master", "sunshine",
+	"princess", "shadow", "superman", "batman", "trustno", "login", "starwars",
+	"hello", "freedom", "whatever", "secret", "summer", "winter", "spring",
+	"autumn", "love", "test", "chirpy", "chirp", "default", "changeme", "hunter",
+	"michael", "charlie", "jordan", "pokemon", "computer", "internet", "cookie",
+}
+
+var keyboardRows = []string{
+	"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890", "qwertzuiop", "azertyuiop",
+}
+
+var leetSubstitutions = map[rune]rune{
+	'0': 'o', '1': 'l', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's', '!': 'i',
+}
+
+// EstimateStrength returns a score from 0 (trivially guessable) to 4 (very
+// strong). Dictionary words, the user's own inputs, keyboard walks, sequences,
+// repeats and years are counted as patterns instead of random characters.
+func EstimateStrength(password string, userInputs ...string) int {
+
+	runes := []rune(strings.ToLower(password))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	covered := make([]bool, len(runes))
+	bits := 0.0
+
+	normalized := make([]rune, len(runes))
+	for i, r := range runes {
+		if sub, ok := leetSubstitutions[r]; ok {
+			normalized[i] = sub
+			continue
+		}
+		normalized[i] = r
+	}
+
+	words := append([]string{}, commonWords...)
+	for _, input := range userInputs {
+		words = append(words, splitUserInput(input)...)
+	}
+
+	dictionaryBits := math.Log2(float64(len(words)))
+	for _, word := range words {
+		if markMatches(normalized, []rune(word), covered) {
+			bits += dictionaryBits
+		}
+	}
+
+	for _, row := range keyboardRows {
+		bits += markRuns(runes, []rune(row), covered)
+		bits += markRuns(runes, []rune(reverse(row)), covered)
+	}
+
+	bits += markSequences(runes, covered)
+	bits += markYears(runes, covered)
+
+	charsetBits := math.Log2(float64(charsetSize(password)))
+	for _, c := range covered {
+		if !c {
+			bits += charsetBits
+		}
+	}
+
+	switch {
+	case bits < 10:
+		return 0
+	case bits < 20:
+		return 1
+	case bits < 27:
+		return 2
+	case bits < 33:
+		return 3
+	default:
+		return 4
+	}
+
+}
+
+func splitUserInput(input string) []string {
+
+	parts := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	words := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if len([]rune(part)) >= 3 {
+			words = append(words, part)
+		}
+	}
+
+	return words
+
+}
+
+// markMatches reports whether the word covered any character that no earlier
+// pattern had claimed yet.
+func markMatches(runes, word []rune, covered []bool) bool {
+
+	found := false
+	for i := 0; i+len(word) <= len(runes); i++ {
+		if string(runes[i:i+len(word)]) != string(word) {
+			continue
+		}
+		for j := i; j < i+len(word); j++ {
+			if !covered[j] {
+				covered[j] = true
+				found = true
+			}
+		}
+	}
+
+	return found
+
+}
+
+func markRuns(runes, row []rune, covered []bool) float64 {
+
+	bits := 0.0
+	for length := len(row); length >= 3; length-- {
+		for start := 0; start+length <= len(row); start++ {
+			if markMatches(runes, row[start:start+length], covered) {
+				bits += math.Log2(float64(len(keyboardRows) * len(row) * 2))
+			}
+		}
+	}
+
+	return bits
+
+}
+
+func markSequences(runes []rune, covered []bool) float64 {
+
+	bits := 0.0
+	i := 0
+	for i < len(runes)-2 {
+		delta := runes[i+1] - runes[i]
+		if delta < -1 || delta > 1 {
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end+1 < len(runes) && runes[end+1]-runes[end] == delta {
+			end++
+		}
+
+		if end-i+1 >= 3 {
+			if markMatches(runes[i:end+1], runes[i:end+1], covered[i:end+1]) {
+				bits += math.Log2(float64(26 * (end - i + 1)))
+			}
+			i = end + 1
+			continue
+		}
+		i++
+	}
+
+	return bits
+
+}
+
+func markYears(runes []rune, covered []bool) float64 {
+
+	bits := 0.0
+	for i := 0; i+4 <= len(runes); i++ {
+		year := string(runes[i : i+4])
+		if !(strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) {
+			continue
+		}
+		if !unicode.IsDigit(runes[i+2]) || !unicode.IsDigit(runes[i+3]) {
+			continue
+		}
+		if markMatches(runes[i:i+4], runes[i:i+4], covered[i:i+4]) {
+			bits += math.Log2(200)
+		}
+	}
+
+	return bits
+
+}
+
+func charsetSize(password string) int {
+
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+
+	return size
+
+}
+
+func reverse(s string) string {
+
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+
+}
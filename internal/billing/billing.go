@@ -0,0 +1,57 @@
+// Package billing hides payment providers behind one interface. Providers
+// turn their webhooks into normalized subscription events, so handlers never
+// see provider specific payloads.
+package billing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Normalized subscription statuses. A canceled subscription keeps its perks
+// until the end of the paid period.
+const (
+	StatusActive   = "active"
+	StatusCanceled = "canceled"
+	StatusExpired  = "expired"
+	StatusRefunded = "refunded"
+)
+
+var (
+	// ErrIgnored is returned for events that do not concern subscriptions.
+	ErrIgnored = errors.New("event is not about subscriptions")
+	// ErrCheckoutUnavailable is returned by providers without a checkout.
+	ErrCheckoutUnavailable = errors.New("checkout is not available")
+)
+
+// Event is a subscription change reported by a provider.
+type Event struct {
+	// ID identifies the event at the provider, so retries can be recognised.
+	// It is empty when the provider sent none. Such webhooks are rejected,
+	// since a retry could not be told from a new event.
+	ID string
+	// Type is the provider's own name for the event.
+	Type   string
+	Status string
+	UserID uuid.UUID
+	// SubscriptionRef is the provider's id of the subscription.
+	SubscriptionRef string
+	// PeriodEnd is nil for subscriptions without a known end.
+	PeriodEnd *time.Time
+}
+
+type Provider interface {
+	// Name is stored with subscriptions and used in webhook URLs.
+	Name() string
+	// Verify checks that a webhook was sent by the provider.
+	Verify(header http.Header, body []byte) error
+	// ParseEvent reads a verified webhook. header is empty when a stored
+	// event is replayed.
+	ParseEvent(header http.Header, body []byte) (Event, error)
+	// CheckoutURL is where the user can buy Chirpy Red.
+	CheckoutURL(ctx context.Context, userID uuid.UUID) (string, error)
+}
@@ -0,0 +1,135 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/webhook"
+)
+
+func TestPolkaVerify(t *testing.T) {
+
+	body := []byte(`{"event":"user.upgraded"}`)
+	signed := http.Header{}
+	signed.Set(PolkaSignatureHeader, webhook.Sign([]string{"secret"}, time.Now(), body))
+
+	type testCase struct {
+		name     string
+		provider *Polka
+		header   http.Header
+		valid    bool
+	}
+
+	runCases := []testCase{
+		{name: "api key", provider: &Polka{APIKey: "key"}, header: http.Header{"Authorization": {"ApiKey key"}}, valid: true},
+		{name: "wrong api key", provider: &Polka{APIKey: "key"}, header: http.Header{"Authorization": {"ApiKey other"}}},
+		{name: "no api key configured", provider: &Polka{}, header: http.Header{"Authorization": {"ApiKey "}}},
+		{name: "signature", provider: &Polka{Secrets: []string{"secret"}}, header: signed, valid: true},
+		{name: "api key when signing", provider: &Polka{APIKey: "key", Secrets: []string{"secret"}}, header: http.Header{"Authorization": {"ApiKey key"}}},
+	}
+
+	for _, test := range runCases {
+		err := test.provider.Verify(test.header, body)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %v, got %v", test.name, test.valid, err)
+		}
+	}
+
+}
+
+func TestPolkaParseEvent(t *testing.T) {
+
+	provider := &Polka{}
+	userID := uuid.MustParse("3311741c-680c-4546-99f3-fc9efac2036c")
+
+	event, err := provider.ParseEvent(http.Header{}, []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.Status != StatusActive || event.UserID != userID || event.SubscriptionRef != userID.String() || event.ID != "" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	header := http.Header{}
+	header.Set(PolkaEventIDHeader, "evt_1")
+	event, err = provider.ParseEvent(header, []byte(`{"event":"user.cancelled","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c","subscription_id":"sub_1"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.ID != "evt_1" || event.Status != StatusCanceled || event.SubscriptionRef != "sub_1" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	// Without the header the period tells retries apart.
+	renewal := []byte(`{"event":"user.renewed","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c","current_period_end":"2026-04-01T00:00:00Z"}}`)
+	first, err := provider.ParseEvent(http.Header{}, renewal)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	retry, _ := provider.ParseEvent(http.Header{}, renewal)
+	next, _ := provider.ParseEvent(http.Header{}, []byte(`{"event":"user.renewed","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c","current_period_end":"2026-05-01T00:00:00Z"}}`))
+	if first.ID == "" || first.ID != retry.ID || first.ID == next.ID {
+		t.Errorf("unexpected derived ids %q, %q and %q", first.ID, retry.ID, next.ID)
+	}
+
+	if _, err := provider.ParseEvent(http.Header{}, []byte(`{"event":"user.created"}`)); !errors.Is(err, ErrIgnored) {
+		t.Errorf("expected ErrIgnored, got %v", err)
+	}
+
+	if _, err := provider.CheckoutURL(context.Background(), userID); !errors.Is(err, ErrCheckoutUnavailable) {
+		t.Errorf("expected ErrCheckoutUnavailable, got %v", err)
+	}
+
+	provider.Checkout = "https://polka.example/checkout?ref={user_id}"
+	url, err := provider.CheckoutURL(context.Background(), userID)
+	if err != nil || url != "https://polka.example/checkout?ref="+userID.String() {
+		t.Errorf("unexpected checkout url %q, %v", url, err)
+	}
+
+}
+
+// Polka bodies only hold the event and the user, so upgrading again after a
+// downgrade repeats the first body. It must not get the id of the first one.
+func TestPolkaUpgradeAfterDowngrade(t *testing.T) {
+
+	provider := &Polka{}
+	upgraded := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	downgraded := []byte(`{"event":"user.downgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+
+	expected := []string{StatusActive, StatusExpired, StatusActive}
+	for i, body := range [][]byte{upgraded, downgraded, upgraded} {
+		event, err := provider.ParseEvent(http.Header{}, body)
+		if err != nil {
+			t.Fatalf("event %d: unexpected error: %v", i, err)
+		}
+		if event.Status != expected[i] {
+			t.Errorf("event %d: expected status %q, got %q", i, expected[i], event.Status)
+		}
+		if event.ID != "" {
+			t.Errorf("event %d: expected no id without %s, got %q", i, PolkaEventIDHeader, event.ID)
+		}
+	}
+
+}
+
+func TestFakeParseEvent(t *testing.T) {
+
+	provider := &Fake{}
+
+	event, err := provider.ParseEvent(nil, []byte(`{"status":"refunded","user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.Status != StatusRefunded || event.Type != "fake.refunded" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	if _, err := provider.ParseEvent(nil, []byte(`{"status":"gold"}`)); err == nil {
+		t.Errorf("expected an error for an unknown status")
+	}
+
+}
@@ -0,0 +1,119 @@
+package billing
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/auth"
+	"github.com/sebasukodo/chirpy/internal/webhook"
+)
+
+const (
+	PolkaSignatureHeader = "Polka-Signature"
+	PolkaEventIDHeader   = "Polka-Event-Id"
+)
+
+var polkaEventStatus = map[string]string{
+	"user.upgraded":   StatusActive,
+	"user.renewed":    StatusActive,
+	"user.cancelled":  StatusCanceled,
+	"user.downgraded": StatusExpired,
+	"user.refunded":   StatusRefunded,
+}
+
+// Polka authenticates webhooks with HMAC signatures when Secrets are set
+// and with the static APIKey otherwise.
+type Polka struct {
+	APIKey string
+	// Secrets may hold the old and the new secret while rotating.
+	Secrets []string
+	// Checkout is the checkout page, "{user_id}" is replaced with the user.
+	Checkout string
+}
+
+type polkaEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		UserID uuid.UUID `json:"user_id"`
+		// SubscriptionID and CurrentPeriodEnd are optional. Without an id a
+		// user has a single subscription, without an end it runs until canceled.
+		SubscriptionID   string     `json:"subscription_id"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+func (p *Polka) Name() string {
+	return "polka"
+}
+
+func (p *Polka) Verify(header http.Header, body []byte) error {
+
+	if len(p.Secrets) > 0 {
+		return webhook.Verify(header.Get(PolkaSignatureHeader), body, p.Secrets, webhook.DefaultTolerance, time.Now())
+	}
+
+	key, err := auth.GetAPIKey(header)
+	if err != nil {
+		return err
+	}
+
+	if p.APIKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(p.APIKey)) != 1 {
+		return errors.New("invalid api key")
+	}
+
+	return nil
+
+}
+
+func (p *Polka) ParseEvent(header http.Header, body []byte) (Event, error) {
+
+	parsed := polkaEvent{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return Event{}, err
+	}
+
+	// Polka bodies carry no id or timestamp, so a later upgrade has the same
+	// body as an earlier one. Without the header only the period tells a
+	// retry from a new event, and without either the event has no id.
+	event := Event{
+		ID:              header.Get(PolkaEventIDHeader),
+		Type:            parsed.Event,
+		UserID:          parsed.Data.UserID,
+		SubscriptionRef: parsed.Data.SubscriptionID,
+		PeriodEnd:       parsed.Data.CurrentPeriodEnd,
+	}
+
+	if event.SubscriptionRef == "" {
+		event.SubscriptionRef = event.UserID.String()
+	}
+
+	if event.ID == "" && event.PeriodEnd != nil {
+		event.ID = fmt.Sprintf("%s/%s/%s", event.Type, event.SubscriptionRef, event.PeriodEnd.UTC().Format(time.RFC3339))
+	}
+
+	status, ok := polkaEventStatus[parsed.Event]
+	if !ok {
+		return event, ErrIgnored
+	}
+	event.Status = status
+
+	return event, nil
+
+}
+
+func (p *Polka) CheckoutURL(ctx context.Context, userID uuid.UUID) (string, error) {
+
+	if p.Checkout == "" {
+		return "", ErrCheckoutUnavailable
+	}
+
+	return strings.ReplaceAll(p.Checkout, "{user_id}", userID.String()), nil
+
+}
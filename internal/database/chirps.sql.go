@@ -7,29 +7,69 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const countChirpsSince = `-- name: CountChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2
+`
+
+type CountChirpsSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountChirpsSince(ctx context.Context, arg CountChirpsSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPinnedChirps = `-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND pin_position IS NOT NULL
+`
+
+func (q *Queries) CountPinnedChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPinnedChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, preview_url, visibility)
 VALUES(
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, edited_at, preview_url, visibility, pin_position
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	PreviewUrl sql.NullString
+	Visibility string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.PreviewUrl,
+		arg.Visibility,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +77,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.PreviewUrl,
+		&i.Visibility,
+		&i.PinPosition,
 	)
 	return i, err
 }
@@ -52,8 +96,10 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.preview_url, chirps.visibility, chirps.pin_position FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+ORDER BY chirps.created_at ASC
 `
 
 func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
@@ -71,6 +117,10 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.PreviewUrl,
+			&i.Visibility,
+			&i.PinPosition,
 		); err != nil {
 			return nil, err
 		}
@@ -86,9 +136,10 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getAllChirpsFromAuthor = `-- name: GetAllChirpsFromAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.preview_url, chirps.visibility, chirps.pin_position FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.deleted_at IS NULL
+ORDER BY chirps.created_at
 `
 
 func (q *Queries) GetAllChirpsFromAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
@@ -106,6 +157,10 @@ func (q *Queries) GetAllChirpsFromAuthor(ctx context.Context, userID uuid.UUID)
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.PreviewUrl,
+			&i.Visibility,
+			&i.PinPosition,
 		); err != nil {
 			return nil, err
 		}
@@ -121,8 +176,9 @@ func (q *Queries) GetAllChirpsFromAuthor(ctx context.Context, userID uuid.UUID)
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.preview_url, chirps.visibility, chirps.pin_position FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.deleted_at IS NULL
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -134,6 +190,10 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.PreviewUrl,
+		&i.Visibility,
+		&i.PinPosition,
 	)
 	return i, err
 }
@@ -149,3 +209,320 @@ func (q *Queries) GetChirpUserID(ctx context.Context, id uuid.UUID) (uuid.UUID,
 	err := row.Scan(&user_id)
 	return user_id, err
 }
+
+const getChirpsAfter = `-- name: GetChirpsAfter :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.preview_url, chirps.visibility, chirps.pin_position FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+AND (chirps.created_at, chirps.id) > ($1::timestamp, $2::uuid)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $3
+`
+
+type GetChirpsAfterParams struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	PageSize  int32
+}
+
+func (q *Queries) GetChirpsAfter(ctx context.Context, arg GetChirpsAfterParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsAfter, arg.CreatedAt, arg.ID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.PreviewUrl,
+			&i.Visibility,
+			&i.PinPosition,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByAuthorPage = `-- name: GetChirpsByAuthorPage :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.preview_url, chirps.visibility, chirps.pin_position FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.deleted_at IS NULL
+AND (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type GetChirpsByAuthorPageParams struct {
+	UserID          uuid.UUID
+	BeforeCreatedAt time.Time
+	BeforeID        uuid.UUID
+	PageSize        int32
+}
+
+func (q *Queries) GetChirpsByAuthorPage(ctx context.Context, arg GetChirpsByAuthorPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorPage,
+		arg.UserID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.PreviewUrl,
+			&i.Visibility,
+			&i.PinPosition,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedChirps = `-- name: GetFeedChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.preview_url, chirps.visibility, chirps.pin_position FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+AND (chirps.user_id = $1 OR chirps.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1))
+ORDER BY chirps.created_at DESC
+LIMIT 100
+`
+
+func (q *Queries) GetFeedChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.PreviewUrl,
+			&i.Visibility,
+			&i.PinPosition,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPinnedChirps = `-- name: GetPinnedChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.preview_url, chirps.visibility, chirps.pin_position FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND chirps.pin_position IS NOT NULL AND users.deleted_at IS NULL
+ORDER BY chirps.pin_position DESC
+`
+
+func (q *Queries) GetPinnedChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getPinnedChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.PreviewUrl,
+			&i.Visibility,
+			&i.PinPosition,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const importChirp = `-- name: ImportChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, visibility)
+VALUES(
+    gen_random_uuid(),
+    $3,
+    $3,
+    $1,
+    $2,
+    $4
+)
+RETURNING id, created_at, updated_at, body, user_id, edited_at, preview_url, visibility, pin_position
+`
+
+type ImportChirpParams struct {
+	Body       string
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	Visibility string
+}
+
+func (q *Queries) ImportChirp(ctx context.Context, arg ImportChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, importChirp,
+		arg.Body,
+		arg.UserID,
+		arg.CreatedAt,
+		arg.Visibility,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.PreviewUrl,
+		&i.Visibility,
+		&i.PinPosition,
+	)
+	return i, err
+}
+
+const lockChirpByID = `-- name: LockChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, preview_url, visibility, pin_position FROM chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, lockChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.PreviewUrl,
+		&i.Visibility,
+		&i.PinPosition,
+	)
+	return i, err
+}
+
+const pinChirp = `-- name: PinChirp :execrows
+UPDATE chirps
+SET pin_position = (
+    SELECT COALESCE(MAX(pinned.pin_position), 0) + 1 FROM chirps AS pinned
+    WHERE pinned.user_id = $2
+)
+WHERE id = $1 AND user_id = $2 AND pin_position IS NULL
+`
+
+type PinChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pinChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unpinChirp = `-- name: UnpinChirp :exec
+UPDATE chirps
+SET pin_position = NULL
+WHERE id = $1 AND user_id = $2
+`
+
+type UnpinChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, unpinChirp, arg.ID, arg.UserID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, preview_url = $3, updated_at = NOW(), edited_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, edited_at, preview_url, visibility, pin_position
+`
+
+type UpdateChirpBodyParams struct {
+	ID         uuid.UUID
+	Body       string
+	PreviewUrl sql.NullString
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body, arg.PreviewUrl)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.PreviewUrl,
+		&i.Visibility,
+		&i.PinPosition,
+	)
+	return i, err
+}
@@ -31,6 +31,42 @@ func (q *Queries) GetSessionIDByID(ctx context.Context, id string) (SessionID, e
 	return i, err
 }
 
+const getSessionsForUser = `-- name: GetSessionsForUser :many
+SELECT id, created_at, updated_at, user_id, expires_at, revoked_at FROM session_ids
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetSessionsForUser(ctx context.Context, userID uuid.UUID) ([]SessionID, error) {
+	rows, err := q.db.QueryContext(ctx, getSessionsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SessionID
+	for rows.Next() {
+		var i SessionID
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const revokeAllExpiredSessionIDs = `-- name: RevokeAllExpiredSessionIDs :exec
 UPDATE session_ids
 SET revoked_at = NOW(), updated_at = NOW()
@@ -0,0 +1,63 @@
+// Package entitlements defines what each plan allows. Features ask for the
+// entitlements of a user instead of checking the plan themselves, so a perk
+// is changed in one place.
+package entitlements
+
+import "time"
+
+const (
+	PlanFree = "free"
+	PlanRed  = "chirpy_red"
+)
+
+type Entitlements struct {
+	Plan string
+	// Badge is shown next to the handle on public profiles, empty for none.
+	Badge           string
+	MaxChirpLength  int
+	EditWindow      time.Duration
+	ChirpsPerHour   int
+	MaxPinnedChirps int
+	MaxBookmarks    int
+	MaxLists        int
+	MaxListMembers  int
+}
+
+// Plans holds the entitlements of every plan by name.
+type Plans map[string]Entitlements
+
+// Default returns the built-in plan definitions.
+func Default() Plans {
+	return Plans{
+		PlanFree: {
+			Plan:            PlanFree,
+			MaxChirpLength:  140,
+			EditWindow:      15 * time.Minute,
+			ChirpsPerHour:   30,
+			MaxPinnedChirps: 1,
+			MaxBookmarks:    1000,
+			MaxLists:        10,
+			MaxListMembers:  100,
+		},
+		PlanRed: {
+			Plan:            PlanRed,
+			Badge:           "Chirpy Red",
+			MaxChirpLength:  500,
+			EditWindow:      time.Hour,
+			ChirpsPerHour:   300,
+			MaxPinnedChirps: 5,
+			MaxBookmarks:    10000,
+			MaxLists:        50,
+			MaxListMembers:  500,
+		},
+	}
+}
+
+// For returns the entitlements of a plan. Unknown plans fall back to the free
+// plan so a typo never grants more than intended.
+func (p Plans) For(plan string) Entitlements {
+	if e, ok := p[plan]; ok {
+		return e
+	}
+	return p[PlanFree]
+}
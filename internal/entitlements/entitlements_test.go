@@ -0,0 +1,22 @@
+package entitlements
+
+import "testing"
+
+func TestFor(t *testing.T) {
+
+	plans := Default()
+
+	if got := plans.For(PlanRed); got.Plan != PlanRed || got.Badge == "" {
+		t.Errorf("unexpected red entitlements: %+v", got)
+	}
+
+	if got := plans.For("platinum"); got.Plan != PlanFree {
+		t.Errorf("expected unknown plan to fall back to free, got %q", got.Plan)
+	}
+
+	free, red := plans.For(PlanFree), plans.For(PlanRed)
+	if red.MaxChirpLength <= free.MaxChirpLength || red.EditWindow <= free.EditWindow || red.MaxPinnedChirps <= free.MaxPinnedChirps {
+		t.Errorf("red should allow more than free: free=%+v red=%+v", free, red)
+	}
+
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event types emitted by the handlers.
+const (
+	TypeChirpCreated    = "chirp.created"
+	TypeChirpDeleted    = "chirp.deleted"
+	TypeUserFollowed    = "user.followed"
+	TypeUserMentioned   = "user.mentioned"
+	TypeSessionsRevoked = "user.sessions_revoked"
+)
+
+// Event describes something that happened to a user. ActorID caused it,
+// UserID is affected by it and ChirpID is set when a chirp is involved.
+type Event struct {
+	Type    string    `json:"type"`
+	ActorID uuid.UUID `json:"actor_id"`
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+	// Remote is set on events published by another instance. Handlers that
+	// write to the database skip them, the publishing instance already did.
+	Remote bool `json:"-"`
+}
+
+type Handler func(ctx context.Context, event Event)
+
+// Bus delivers published events to the handlers subscribed to their type.
+type Bus interface {
+	Subscribe(eventType string, handler Handler)
+	Publish(ctx context.Context, event Event)
+}
+
+// Memory is a Bus for a single process. Handlers run in subscription order
+// on the publishing goroutine.
+type Memory struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewMemory() *Memory {
+	return &Memory{handlers: map[string][]Handler{}}
+}
+
+func (b *Memory) Subscribe(eventType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+func (b *Memory) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Type]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+// Postgres is a Bus shared by every instance connected to the same database.
+// Events are dispatched locally right away and sent to the other instances
+// with NOTIFY. Delivery to other instances is best effort: events sent while
+// a listener reconnects are lost.
+type Postgres struct {
+	db       *sql.DB
+	channel  string
+	origin   uuid.UUID
+	local    *Memory
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+type notification struct {
+	Origin uuid.UUID `json:"origin"`
+	Event  Event     `json:"event"`
+}
+
+// NewPostgres listens on channel with a dedicated connection to dsn and
+// publishes through db. Close stops the listener.
+func NewPostgres(db *sql.DB, dsn string, channel string) (*Postgres, error) {
+
+	bus := &Postgres{
+		db:      db,
+		channel: channel,
+		origin:  uuid.New(),
+		local:   NewMemory(),
+		done:    make(chan struct{}),
+	}
+
+	bus.listener = pq.NewListener(dsn, listenerMinReconnect, listenerMaxReconnect, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			log.Printf("event listener disconnected: %v", err)
+		case pq.ListenerEventReconnected:
+			log.Printf("event listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Printf("event listener could not connect: %v", err)
+		}
+	})
+
+	if err := bus.listener.Listen(channel); err != nil {
+		bus.listener.Close()
+		return nil, err
+	}
+
+	go bus.run()
+
+	return bus, nil
+
+}
+
+func (b *Postgres) Subscribe(eventType string, handler Handler) {
+	b.local.Subscribe(eventType, handler)
+}
+
+func (b *Postgres) Publish(ctx context.Context, event Event) {
+
+	b.local.Publish(ctx, event)
+
+	payload, err := json.Marshal(notification{Origin: b.origin, Event: event})
+	if err != nil {
+		log.Printf("could not encode %s event: %v", event.Type, err)
+		return
+	}
+
+	if _, err := b.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", b.channel, string(payload)); err != nil {
+		log.Printf("could not send %s event to other instances: %v", event.Type, err)
+	}
+
+}
+
+func (b *Postgres) Close() error {
+	close(b.done)
+	return b.listener.Close()
+}
+
+func (b *Postgres) run() {
+
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// The listener sends nil after it reconnected.
+			if n == nil {
+				continue
+			}
+
+			received := notification{}
+			if err := json.Unmarshal([]byte(n.Extra), &received); err != nil {
+				log.Printf("could not decode event: %v", err)
+				continue
+			}
+
+			if received.Origin == b.origin {
+				continue
+			}
+
+			received.Event.Remote = true
+			b.local.Publish(context.Background(), received.Event)
+
+		case <-ping.C:
+			// Detects dead connections that would otherwise go unnoticed
+			// until the next NOTIFY.
+			go b.listener.Ping()
+		}
+	}
+
+}
@@ -0,0 +1,59 @@
+// Package handle validates the @names users are addressed by. Handles are
+// unique ignoring case but keep the case they were chosen with.
+package handle
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 20
+)
+
+// reserved handles would collide with routes or look official.
+var reserved = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "chirpy": true,
+	"help": true, "login": true, "logout": true, "me": true,
+	"media": true, "moderator": true, "register": true, "root": true,
+	"settings": true, "static": true, "support": true, "system": true,
+}
+
+// Normalize strips a leading @ and surrounding spaces.
+func Normalize(h string) string {
+	return strings.TrimPrefix(strings.TrimSpace(h), "@")
+}
+
+// Validate checks a normalized handle.
+func Validate(h string) error {
+
+	if len(h) < MinLength || len(h) > MaxLength {
+		return fmt.Errorf("handle must be between %d and %d characters", MinLength, MaxLength)
+	}
+
+	for _, c := range h {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return fmt.Errorf("handle can only contain letters, digits and underscores")
+		}
+	}
+
+	if reserved[strings.ToLower(h)] {
+		return fmt.Errorf("handle %q is reserved", h)
+	}
+
+	return nil
+
+}
+
+// Generate returns a random valid handle for accounts that did not pick one.
+func Generate() string {
+
+	b := make([]byte, 6)
+	rand.Read(b)
+
+	return "user_" + hex.EncodeToString(b)
+
+}
@@ -0,0 +1,35 @@
+package handle
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+
+	type testCase struct {
+		handle string
+		valid  bool
+	}
+
+	runCases := []testCase{
+		{handle: "chirper", valid: true},
+		{handle: "Chirp_42", valid: true},
+		{handle: "ab"},
+		{handle: "abcdefghijklmnopqrstu"},
+		{handle: "with space"},
+		{handle: "dash-ed"},
+		{handle: "émile"},
+		{handle: "Admin"},
+		{handle: "me"},
+		{handle: Generate(), valid: true},
+	}
+
+	for _, test := range runCases {
+		if err := Validate(test.handle); (err == nil) != test.valid {
+			t.Errorf("Validate(%q) = %v, expected valid %v", test.handle, err, test.valid)
+		}
+	}
+
+	if got := Normalize("  @chirper "); got != "chirper" {
+		t.Errorf("Normalize returned %q", got)
+	}
+
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+)
+
+const (
+	AccountEventRegistered       = "registered"
+	AccountEventEmailChanged     = "email_changed"
+	AccountEventPasswordChanged  = "password_changed"
+	AccountEventPasswordRehashed = "password_rehashed"
+	AccountEventDeactivated      = "deactivated"
+	AccountEventRestored         = "restored"
+	AccountEventExportRequested  = "export_requested"
+)
+
+func (cfg *ApiConfig) recordAccountEvent(ctx context.Context, userID uuid.UUID, event string, detail string) {
+
+	if err := cfg.DbQueries.CreateAccountEvent(ctx, database.CreateAccountEventParams{
+		UserID: userID,
+		Event:  event,
+		Detail: detail,
+	}); err != nil {
+		log.Printf("could not record account event %s for user %v: %v", event, userID, err)
+	}
+
+}
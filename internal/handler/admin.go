@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/sebasukodo/chirpy/internal/auth"
+)
+
+type passwordHashReport struct {
+	Total    int `json:"total"`
+	Current  int `json:"current"`
+	Outdated int `json:"outdated"`
+	Legacy   int `json:"legacy"`
+	Unset    int `json:"unset"`
+}
+
+func (cfg *ApiConfig) MiddlewareAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key, err := auth.GetAPIKey(r.Header)
+		if err != nil || cfg.AdminApiKey == "" {
+			respondWithError(w, r, 401, "Access Denied")
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(key), []byte(cfg.AdminApiKey)) != 1 {
+			respondWithError(w, r, 401, "Access Denied")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *ApiConfig) AdminPasswordHashReport(w http.ResponseWriter, r *http.Request) {
+
+	hashes, err := cfg.DbQueries.ListUserPasswordHashes(r.Context())
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve password hashes")
+		return
+	}
+
+	report := passwordHashReport{Total: len(hashes)}
+
+	for _, hash := range hashes {
+		switch auth.GetHashStatus(hash, cfg.HashParams) {
+		case auth.HashCurrent:
+			report.Current++
+		case auth.HashOutdated:
+			report.Outdated++
+		case auth.HashLegacy:
+			report.Legacy++
+		default:
+			report.Unset++
+		}
+	}
+
+	respondWithJSON(w, 200, report)
+
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/billing"
+)
+
+// BillingCheckout returns the checkout URL of a provider, the configured
+// checkout provider unless one is requested.
+func (cfg *ApiConfig) BillingCheckout(w http.ResponseWriter, r *http.Request) {
+
+	request := struct {
+		Provider string `json:"provider"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			respondWithError(w, r, 400, "could not decode json message")
+			return
+		}
+	}
+
+	providerName := request.Provider
+	if providerName == "" {
+		providerName = cfg.CheckoutProvider
+	}
+
+	provider, ok := cfg.BillingProviders[providerName]
+	if !ok {
+		respondWithError(w, r, 404, "unknown billing provider")
+		return
+	}
+
+	url, err := provider.CheckoutURL(r.Context(), userIDFromContext(r.Context()))
+	if errors.Is(err, billing.ErrCheckoutUnavailable) {
+		respondWithError(w, r, 503, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("could not create %s checkout: %v", providerName, err)
+		respondWithError(w, r, 500, "could not create checkout")
+		return
+	}
+
+	respondWithJSON(w, 200, struct {
+		URL string `json:"url"`
+	}{URL: url})
+
+}
+
+// BillingCheckoutPage sends the browser to the checkout of the configured
+// provider.
+func (cfg *ApiConfig) BillingCheckoutPage(w http.ResponseWriter, r *http.Request) {
+
+	provider, ok := cfg.BillingProviders[cfg.CheckoutProvider]
+	if !ok {
+		respondWithError(w, r, 503, "checkout is not available")
+		return
+	}
+
+	url, err := provider.CheckoutURL(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 503, "checkout is not available")
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusSeeOther)
+
+}
+
+// BillingFakeCheckout completes a checkout of the fake provider. The event
+// goes through the same pipeline as a webhook, so it shows up in the admin
+// webhook list and can be replayed.
+func (cfg *ApiConfig) BillingFakeCheckout(w http.ResponseWriter, r *http.Request) {
+
+	provider, ok := cfg.BillingProviders["fake"]
+	if !ok {
+		respondWithError(w, r, 404, "Not Found")
+		return
+	}
+
+	body, err := json.Marshal(billing.FakeEvent{
+		ID:     uuid.NewString(),
+		Status: billing.StatusActive,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "Internal Error")
+		return
+	}
+
+	billingEvent, err := provider.ParseEvent(http.Header{}, body)
+	if err != nil {
+		respondWithError(w, r, 500, "Internal Error")
+		return
+	}
+
+	event, err := cfg.DbQueries.RecordWebhookEvent(r.Context(), recordWebhookEventParams(provider, billingEvent, body))
+	if err != nil {
+		respondWithError(w, r, 500, "Internal Error")
+		return
+	}
+
+	if err := cfg.processWebhookEvent(r.Context(), event); err != nil {
+		log.Printf("could not process fake checkout %v: %v", event.ID, err)
+		respondWithError(w, r, 500, "Internal Error")
+		return
+	}
+
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+
+}
@@ -0,0 +1,338 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+)
+
+const (
+	MaxMuteKeywordLength = 100
+	MaxMuteHours         = 24 * 365
+)
+
+type muteRequest struct {
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+type keywordMuteRequest struct {
+	Keyword        string `json:"keyword"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+}
+
+type userMuteResponse struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type keywordMuteResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Keyword   string     `json:"keyword"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type mutesResponse struct {
+	Users    []userMuteResponse    `json:"users"`
+	Keywords []keywordMuteResponse `json:"keywords"`
+}
+
+// UsersBlock hides both users from each other and ends follows between them.
+func (cfg *ApiConfig) UsersBlock(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	if blockedID == userID {
+		respondWithError(w, r, 400, "you cannot block yourself")
+		return
+	}
+
+	if _, err := cfg.DbQueries.GetUserByID(r.Context(), blockedID); err != nil {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not block user")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	if err := queries.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not block user")
+		return
+	}
+
+	if err := queries.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+		FollowerID: userID,
+		FolloweeID: blockedID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not block user")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not block user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) UsersUnblock(w http.ResponseWriter, r *http.Request) {
+
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	if err := cfg.DbQueries.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userIDFromContext(r.Context()),
+		BlockedID: blockedID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not unblock user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// UsersMute hides the user's chirps from the caller's listings without them
+// knowing. The body is optional, without expires_in_hours the mute is permanent.
+func (cfg *ApiConfig) UsersMute(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	mutedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	if mutedID == userID {
+		respondWithError(w, r, 400, "you cannot mute yourself")
+		return
+	}
+
+	req := muteRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, r, 400, "could not decode json message")
+			return
+		}
+	}
+
+	expiresAt, err := muteExpiry(req.ExpiresInHours)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	if _, err := cfg.DbQueries.GetUserByID(r.Context(), mutedID); err != nil {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	if err := cfg.DbQueries.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID:   userID,
+		MutedID:   mutedID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not mute user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) UsersUnmute(w http.ResponseWriter, r *http.Request) {
+
+	mutedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	if err := cfg.DbQueries.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userIDFromContext(r.Context()),
+		MutedID: mutedID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not unmute user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) MutesList(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	mutes, err := cfg.DbQueries.GetMutesForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve mutes")
+		return
+	}
+
+	keywordMutes, err := cfg.DbQueries.GetKeywordMutesForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve mutes")
+		return
+	}
+
+	response := mutesResponse{
+		Users:    make([]userMuteResponse, 0, len(mutes)),
+		Keywords: make([]keywordMuteResponse, 0, len(keywordMutes)),
+	}
+
+	for _, mute := range mutes {
+		response.Users = append(response.Users, userMuteResponse{
+			UserID:    mute.MutedID,
+			CreatedAt: mute.CreatedAt,
+			ExpiresAt: nullTimePtr(mute.ExpiresAt),
+		})
+	}
+
+	for _, mute := range keywordMutes {
+		response.Keywords = append(response.Keywords, convertKeywordMute(mute))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) KeywordMutesCreate(w http.ResponseWriter, r *http.Request) {
+
+	req := keywordMuteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	keyword := strings.ToLower(strings.TrimSpace(req.Keyword))
+	if keyword == "" || len(keyword) > MaxMuteKeywordLength {
+		respondWithError(w, r, 400, fmt.Sprintf("keyword must be between 1 and %d characters", MaxMuteKeywordLength))
+		return
+	}
+
+	expiresAt, err := muteExpiry(req.ExpiresInHours)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	mute, err := cfg.DbQueries.CreateKeywordMute(r.Context(), database.CreateKeywordMuteParams{
+		UserID:    userIDFromContext(r.Context()),
+		Keyword:   keyword,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not mute keyword")
+		return
+	}
+
+	respondWithJSON(w, 201, convertKeywordMute(mute))
+
+}
+
+func (cfg *ApiConfig) KeywordMutesDelete(w http.ResponseWriter, r *http.Request) {
+
+	muteID, err := uuid.Parse(r.PathValue("muteID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid mute id")
+		return
+	}
+
+	deleted, err := cfg.DbQueries.DeleteKeywordMute(r.Context(), database.DeleteKeywordMuteParams{
+		ID:     muteID,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not delete mute")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, r, 404, "mute not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// CleanupExpiredMutes removes mutes that no longer apply. Queries already
+// ignore them, this only keeps the tables small.
+func (cfg *ApiConfig) CleanupExpiredMutes(ctx context.Context) error {
+
+	if _, err := cfg.DbQueries.DeleteExpiredMutes(ctx); err != nil {
+		return err
+	}
+
+	_, err := cfg.DbQueries.DeleteExpiredKeywordMutes(ctx)
+	return err
+
+}
+
+// hasBlockWith reports whether userID and any of otherIDs blocked each other.
+func (cfg *ApiConfig) hasBlockWith(ctx context.Context, userID uuid.UUID, otherIDs []uuid.UUID) (bool, error) {
+	return cfg.DbQueries.HasBlockBetween(ctx, database.HasBlockBetweenParams{
+		UserID:   userID,
+		OtherIds: otherIDs,
+	})
+}
+
+func muteExpiry(hours int) (sql.NullTime, error) {
+
+	if hours == 0 {
+		return sql.NullTime{}, nil
+	}
+
+	if hours < 0 || hours > MaxMuteHours {
+		return sql.NullTime{}, fmt.Errorf("expires_in_hours must be between 1 and %d", MaxMuteHours)
+	}
+
+	return sql.NullTime{Time: time.Now().UTC().Add(time.Duration(hours) * time.Hour), Valid: true}, nil
+
+}
+
+func convertKeywordMute(mute database.KeywordMute) keywordMuteResponse {
+	return keywordMuteResponse{
+		ID:        mute.ID,
+		CreatedAt: mute.CreatedAt,
+		Keyword:   mute.Keyword,
+		ExpiresAt: nullTimePtr(mute.ExpiresAt),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+// ChirpsBookmark saves a chirp the user can see. Bookmarks are private.
+func (cfg *ApiConfig) ChirpsBookmark(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid chirp id")
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	if visible, err := cfg.canViewChirp(r.Context(), userID, chirp); err != nil || !visible {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	user, err := cfg.DbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	count, err := cfg.DbQueries.CountBookmarks(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not bookmark chirp")
+		return
+	}
+
+	if limit := cfg.entitlementsFor(user).MaxBookmarks; count >= int64(limit) {
+		respondWithError(w, r, 403, fmt.Sprintf("you can have at most %d bookmarks", limit))
+		return
+	}
+
+	if _, err := cfg.DbQueries.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not bookmark chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) ChirpsUnbookmark(w http.ResponseWriter, r *http.Request) {
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid chirp id")
+		return
+	}
+
+	if err := cfg.DbQueries.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userIDFromContext(r.Context()),
+		ChirpID: chirpID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not remove bookmark")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// BookmarksList pages through the user's bookmarks, most recently saved
+// first. The cursor is the id of the last chirp.
+func (cfg *ApiConfig) BookmarksList(w http.ResponseWriter, r *http.Request) {
+
+	pageSize, err := parsePageSize(r, DefaultChirpPageSize, MaxChirpPageSize)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	page, err := cfg.bookmarksPage(r.Context(), userIDFromContext(r.Context()), r.URL.Query().Get("before"), pageSize)
+	if errors.Is(err, errInvalidCursor) {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve bookmarks")
+		return
+	}
+
+	respondWithJSON(w, 200, page)
+
+}
+
+func (cfg *ApiConfig) BookmarksPage(w http.ResponseWriter, r *http.Request) {
+
+	page, err := cfg.bookmarksPage(r.Context(), userIDFromContext(r.Context()), "", MaxChirpPageSize)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve bookmarks")
+		return
+	}
+
+	respondWithHTML(templates.Layout(templates.Bookmarks(convertChirpViews(page.Chirps)), "Bookmarks"), w, r)
+
+}
+
+func (cfg *ApiConfig) bookmarksPage(ctx context.Context, userID uuid.UUID, before string, pageSize int) (chirpPageResponse, error) {
+
+	params := database.GetBookmarkedChirpsParams{
+		UserID:          userID,
+		BeforeCreatedAt: pageStart,
+		BeforeChirpID:   uuid.Max,
+		PageSize:        int32(pageSize),
+	}
+
+	if before != "" {
+		cursorID, err := uuid.Parse(before)
+		if err != nil {
+			return chirpPageResponse{}, errInvalidCursor
+		}
+
+		cursor, err := cfg.DbQueries.GetBookmark(ctx, database.GetBookmarkParams{
+			UserID:  userID,
+			ChirpID: cursorID,
+		})
+		if err != nil {
+			return chirpPageResponse{}, errInvalidCursor
+		}
+
+		params.BeforeCreatedAt = cursor.CreatedAt
+		params.BeforeChirpID = cursor.ChirpID
+	}
+
+	chirps, err := cfg.DbQueries.GetBookmarkedChirps(ctx, params)
+	if err != nil {
+		return chirpPageResponse{}, err
+	}
+
+	return cfg.chirpPage(ctx, userID, chirps, pageSize, false)
+
+}
+
+// chirpPage builds one page of a chirp listing. The cursor comes from the
+// unfiltered rows, so hidden chirps do not end the pagination early.
+func (cfg *ApiConfig) chirpPage(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp, pageSize int, listing bool) (chirpPageResponse, error) {
+
+	page := chirpPageResponse{}
+	if len(chirps) == pageSize {
+		page.NextCursor = chirps[len(chirps)-1].ID.String()
+	}
+
+	visible, err := cfg.visibleChirps(ctx, viewerID, chirps, listing)
+	if err != nil {
+		return chirpPageResponse{}, err
+	}
+
+	page.Chirps, err = cfg.buildChirpResponses(ctx, viewerID, visible)
+	if err != nil {
+		return chirpPageResponse{}, err
+	}
+
+	return page, nil
+
+}
+
+func convertChirpViews(chirps []chirpResponse) []templates.ChirpView {
+
+	views := make([]templates.ChirpView, 0, len(chirps))
+	for _, chirp := range chirps {
+		views = append(views, convertChirpView(chirp))
+	}
+
+	return views
+
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+)
+
+type chirpRevisionResponse struct {
+	Body       string    `json:"body"`
+	WrittenAt  time.Time `json:"written_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type chirpHistoryResponse struct {
+	Chirp     chirpResponse           `json:"chirp"`
+	Revisions []chirpRevisionResponse `json:"revisions"`
+}
+
+func (cfg *ApiConfig) ChirpsUpdate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid chirp id")
+		return
+	}
+
+	chirpReq := chirpCreateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&chirpReq); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, r, 403, "Access Denied")
+		return
+	}
+
+	user, err := cfg.DbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	perks := cfg.entitlementsFor(user)
+
+	if time.Now().UTC().After(chirp.CreatedAt.Add(perks.EditWindow)) {
+		respondWithError(w, r, 403, "chirp can no longer be edited")
+		return
+	}
+
+	body, err := validateChirpBody(chirpReq.Body, perks.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not update chirp")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	// Re-read under a row lock so concurrent edits each store the body they replace.
+	chirp, err = queries.LockChirpByID(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not update chirp")
+		return
+	}
+
+	if body == chirp.Body {
+		cfg.respondWithChirp(w, r, chirp)
+		return
+	}
+
+	writtenAt := chirp.CreatedAt
+	if chirp.EditedAt.Valid {
+		writtenAt = chirp.EditedAt.Time
+	}
+
+	if err := queries.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID:   chirp.ID,
+		Body:      chirp.Body,
+		WrittenAt: writtenAt,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not update chirp")
+		return
+	}
+
+	updated, err := queries.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:         chirp.ID,
+		Body:       body,
+		PreviewUrl: previewURL(body),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not update chirp")
+		return
+	}
+
+	if updated.PreviewUrl.Valid {
+		if err := cfg.queueLinkPreview(r.Context(), tx, updated.PreviewUrl.String); err != nil {
+			respondWithError(w, r, 500, "could not update chirp")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not update chirp")
+		return
+	}
+
+	cfg.respondWithChirp(w, r, updated)
+
+}
+
+func (cfg *ApiConfig) ChirpsHistory(w http.ResponseWriter, r *http.Request) {
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid chirp id")
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	if visible, err := cfg.canViewChirp(r.Context(), userIDFromContext(r.Context()), chirp); err != nil || !visible {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	revisions, err := cfg.DbQueries.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirp history")
+		return
+	}
+
+	current, err := cfg.buildChirpResponse(r.Context(), userIDFromContext(r.Context()), chirp)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirp history")
+		return
+	}
+
+	response := chirpHistoryResponse{
+		Chirp:     current,
+		Revisions: make([]chirpRevisionResponse, 0, len(revisions)),
+	}
+
+	for _, revision := range revisions {
+		response.Revisions = append(response.Revisions, chirpRevisionResponse{
+			Body:       revision.Body,
+			WrittenAt:  revision.WrittenAt,
+			ReplacedAt: revision.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) respondWithChirp(w http.ResponseWriter, r *http.Request, chirp database.Chirp) {
+
+	response, err := cfg.buildChirpResponse(r.Context(), userIDFromContext(r.Context()), chirp)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirp")
+		return
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,20 +12,44 @@ import (
 	"github.com/google/uuid"
 	"github.com/sebasukodo/chirpy/internal/auth"
 	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
+	"github.com/sebasukodo/chirpy/internal/visibility"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	MaxMentions = 10
 )
 
 var slurs = [3]string{"kerfuffle", "sharbert", "fornax"}
 
 type chirpCreateRequest struct {
-	Body string `json:"body"`
+	Body             string             `json:"body"`
+	MediaIDs         []uuid.UUID        `json:"media_ids"`
+	Poll             *pollCreateRequest `json:"poll"`
+	Visibility       string             `json:"visibility"`
+	MentionedUserIDs []uuid.UUID        `json:"mentioned_user_ids"`
 }
 
 type chirpResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID         uuid.UUID            `json:"id"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+	Body       string               `json:"body"`
+	UserID     uuid.UUID            `json:"user_id"`
+	Visibility string               `json:"visibility"`
+	Edited     bool                 `json:"edited"`
+	EditedAt   *time.Time           `json:"edited_at,omitempty"`
+	Media      []mediaResponse      `json:"media"`
+	Preview    *linkPreviewResponse `json:"preview,omitempty"`
+	Poll       *pollResponse        `json:"poll,omitempty"`
+}
+
+// authorChirpsResponse is returned when chirps are filtered by author, so the
+// pinned chirps can be shown above the others.
+type authorChirpsResponse struct {
+	Chirps []chirpResponse `json:"chirps"`
+	Pinned []chirpResponse `json:"pinned"`
 }
 
 func (cfg *ApiConfig) ChirpsGetAll(w http.ResponseWriter, r *http.Request) {
@@ -37,6 +62,7 @@ func (cfg *ApiConfig) ChirpsGetAll(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var chirps []database.Chirp
+	authorID := uuid.Nil
 	if queryAuthor == "" {
 		var err error
 		chirps, err = cfg.DbQueries.GetAllChirps(r.Context())
@@ -47,13 +73,14 @@ func (cfg *ApiConfig) ChirpsGetAll(w http.ResponseWriter, r *http.Request) {
 
 	} else {
 
-		query, err := uuid.Parse(queryAuthor)
+		var err error
+		authorID, err = uuid.Parse(queryAuthor)
 		if err != nil {
 			respondWithError(w, r, 400, "invalid author_id")
 			return
 		}
 
-		chirps, err = cfg.DbQueries.GetAllChirpsFromAuthor(r.Context(), query)
+		chirps, err = cfg.DbQueries.GetAllChirpsFromAuthor(r.Context(), authorID)
 		if err != nil {
 			respondWithError(w, r, 500, "could not retrieve chirps")
 			return
@@ -61,6 +88,12 @@ func (cfg *ApiConfig) ChirpsGetAll(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	chirps, err := cfg.visibleChirps(r.Context(), userIDFromContext(r.Context()), chirps, true)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
+
 	sort.Slice(chirps, func(i int, j int) bool {
 		if querySort == "desc" {
 			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
@@ -68,14 +101,27 @@ func (cfg *ApiConfig) ChirpsGetAll(w http.ResponseWriter, r *http.Request) {
 		return chirps[i].CreatedAt.Before(chirps[j].CreatedAt)
 	})
 
-	sortedChirps := make([]chirpResponse, 0, len(chirps))
-	for _, chirp := range chirps {
+	sortedChirps, err := cfg.buildChirpResponses(r.Context(), userIDFromContext(r.Context()), chirps)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
 
-		sortedChirps = append(sortedChirps, convertDatabaseChirp(chirp))
+	if authorID == uuid.Nil {
+		respondWithJSON(w, 200, sortedChirps)
+		return
+	}
 
+	pinned, err := cfg.pinnedChirps(r.Context(), userIDFromContext(r.Context()), authorID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
 	}
 
-	respondWithJSON(w, 200, sortedChirps)
+	respondWithJSON(w, 200, authorChirpsResponse{
+		Chirps: sortedChirps,
+		Pinned: pinned,
+	})
 
 }
 
@@ -95,7 +141,18 @@ func (cfg *ApiConfig) ChirpsGetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, 200, convertDatabaseChirp(chirp))
+	if visible, err := cfg.canViewChirp(r.Context(), userIDFromContext(r.Context()), chirp); err != nil || !visible {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	response, err := cfg.buildChirpResponse(r.Context(), userIDFromContext(r.Context()), chirp)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirp")
+		return
+	}
+
+	respondWithJSON(w, 200, response)
 
 }
 
@@ -116,29 +173,159 @@ func (cfg *ApiConfig) ChirpsCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uid, err := auth.ValidateJWT(bearer, cfg.TokenSecret)
+	uid, err := cfg.validateBearer(r.Context(), bearer)
 	if err != nil {
 		respondWithError(w, r, 401, "Access Denied")
 		return
 	}
 
-	if len(chirpReq.Body) > 140 {
-		respondWithError(w, r, 400, "Chirp is too long")
+	perks, err := cfg.userEntitlements(r.Context(), uid)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	body, err := validateChirpBody(chirpReq.Body, perks.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
 		return
 	}
 
+	recent, err := cfg.DbQueries.CountChirpsSince(r.Context(), database.CountChirpsSinceParams{
+		UserID:    uid,
+		CreatedAt: time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not create chirp")
+		return
+	}
+
+	if recent >= int64(perks.ChirpsPerHour) {
+		w.Header().Set("Retry-After", "3600")
+		respondWithError(w, r, 429, fmt.Sprintf("you can post at most %d chirps per hour", perks.ChirpsPerHour))
+		return
+	}
+
+	if chirpReq.Visibility == "" {
+		chirpReq.Visibility = visibility.Public
+	}
+
+	if !visibility.Valid(chirpReq.Visibility) {
+		respondWithError(w, r, 400, "visibility must be one of public, followers, unlisted or direct")
+		return
+	}
+
+	mentions := uniqueIDs(chirpReq.MentionedUserIDs)
+	if len(mentions) > MaxMentions {
+		respondWithError(w, r, 400, fmt.Sprintf("a chirp can mention at most %d users", MaxMentions))
+		return
+	}
+
+	if chirpReq.Visibility == visibility.Direct && len(mentions) == 0 {
+		respondWithError(w, r, 400, "direct chirps need at least one mentioned user")
+		return
+	}
+
+	if len(mentions) > 0 {
+		if blocked, err := cfg.hasBlockWith(r.Context(), uid, mentions); err != nil || blocked {
+			respondWithError(w, r, 403, "you cannot mention this user")
+			return
+		}
+	}
+
+	var pollOptions []string
+	var pollDuration time.Duration
+	if chirpReq.Poll != nil {
+		pollOptions, pollDuration, err = validatePoll(chirpReq.Poll)
+		if err != nil {
+			respondWithError(w, r, 400, err.Error())
+			return
+		}
+	}
+
 	chirpParam := database.CreateChirpParams{
-		Body:   removeSlurs(chirpReq.Body),
-		UserID: uid,
+		Body:       body,
+		UserID:     uid,
+		PreviewUrl: previewURL(body),
+		Visibility: chirpReq.Visibility,
 	}
 
-	data, err := cfg.DbQueries.CreateChirp(r.Context(), chirpParam)
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not create chirp")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	data, err := queries.CreateChirp(r.Context(), chirpParam)
 	if err != nil {
 		respondWithError(w, r, 500, fmt.Sprintf("could not create chirp: %v", err))
 		return
 	}
 
-	respondWithJSON(w, 201, convertDatabaseChirp(data))
+	if err := attachMedia(r.Context(), queries, data.ID, uid, chirpReq.MediaIDs); err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("could not attach media: %v", err))
+		return
+	}
+
+	if len(mentions) > 0 {
+		stored, err := queries.CreateChirpMentions(r.Context(), database.CreateChirpMentionsParams{
+			ChirpID: data.ID,
+			UserIds: mentions,
+		})
+		if err != nil {
+			respondWithError(w, r, 500, "could not create chirp")
+			return
+		}
+		if stored != int64(len(mentions)) {
+			respondWithError(w, r, 400, "mentioned user not found")
+			return
+		}
+	}
+
+	if pollOptions != nil {
+		if err := createPoll(r.Context(), queries, data.ID, pollOptions, pollDuration); err != nil {
+			respondWithError(w, r, 500, "could not create poll")
+			return
+		}
+	}
+
+	if data.PreviewUrl.Valid {
+		if err := cfg.queueLinkPreview(r.Context(), tx, data.PreviewUrl.String); err != nil {
+			respondWithError(w, r, 500, "could not create chirp")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not create chirp")
+		return
+	}
+
+	cfg.Events.Publish(r.Context(), events.Event{
+		Type:    events.TypeChirpCreated,
+		ActorID: uid,
+		ChirpID: data.ID,
+	})
+
+	for _, mentioned := range mentions {
+		cfg.Events.Publish(r.Context(), events.Event{
+			Type:    events.TypeUserMentioned,
+			ActorID: uid,
+			UserID:  mentioned,
+			ChirpID: data.ID,
+		})
+	}
+
+	response, err := cfg.buildChirpResponse(r.Context(), uid, data)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirp")
+		return
+	}
+
+	respondWithJSON(w, 201, response)
 
 }
 
@@ -150,7 +337,7 @@ func (cfg *ApiConfig) ChirpsDeleteByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(bearer, cfg.TokenSecret)
+	userID, err := cfg.validateBearer(r.Context(), bearer)
 	if err != nil {
 		respondWithError(w, r, 401, "Access Denied")
 		return
@@ -180,10 +367,42 @@ func (cfg *ApiConfig) ChirpsDeleteByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg.Events.Publish(r.Context(), events.Event{
+		Type:    events.TypeChirpDeleted,
+		ActorID: userID,
+		ChirpID: chirpID,
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 
 }
 
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+
+	unique := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+
+}
+
+func validateChirpBody(body string, maxLength int) (string, error) {
+
+	if len(body) > maxLength {
+		return "", fmt.Errorf("Chirp is too long")
+	}
+
+	return removeSlurs(body), nil
+
+}
+
 func removeSlurs(msg string) string {
 
 	splittedMsg := strings.Split(msg, " ")
@@ -204,11 +423,134 @@ func removeSlurs(msg string) string {
 }
 
 func convertDatabaseChirp(dbChirp database.Chirp) chirpResponse {
-	return chirpResponse{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
+
+	chirp := chirpResponse{
+		ID:         dbChirp.ID,
+		CreatedAt:  dbChirp.CreatedAt,
+		UpdatedAt:  dbChirp.UpdatedAt,
+		Body:       dbChirp.Body,
+		UserID:     dbChirp.UserID,
+		Visibility: dbChirp.Visibility,
+		Edited:     dbChirp.EditedAt.Valid,
+		Media:      []mediaResponse{},
+	}
+
+	if dbChirp.EditedAt.Valid {
+		chirp.EditedAt = &dbChirp.EditedAt.Time
+	}
+
+	return chirp
+
+}
+
+// buildChirpResponses converts chirps and loads their attachments, link
+// previews and polls with one query each. viewerID decides which poll results
+// are visible and is uuid.Nil for anonymous requests.
+func (cfg *ApiConfig) buildChirpResponses(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp) ([]chirpResponse, error) {
+
+	responses := make([]chirpResponse, 0, len(chirps))
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	urls := []string{}
+
+	for _, chirp := range chirps {
+		responses = append(responses, convertDatabaseChirp(chirp))
+		chirpIDs = append(chirpIDs, chirp.ID)
+		if chirp.PreviewUrl.Valid {
+			urls = append(urls, chirp.PreviewUrl.String)
+		}
+	}
+
+	if len(chirpIDs) == 0 {
+		return responses, nil
 	}
+
+	attachments, err := cfg.DbQueries.GetMediaForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byChirp := map[uuid.UUID][]mediaResponse{}
+	for _, medium := range attachments {
+		byChirp[medium.ChirpID.UUID] = append(byChirp[medium.ChirpID.UUID], convertDatabaseMedia(medium))
+	}
+
+	polls, err := cfg.loadPolls(ctx, viewerID, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := map[string]*linkPreviewResponse{}
+	if len(urls) > 0 {
+		links, err := cfg.DbQueries.GetReadyLinkPreviews(ctx, urls)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			previews[link.Url] = convertDatabaseLinkPreview(link)
+		}
+	}
+
+	for i, chirp := range chirps {
+		if media, ok := byChirp[chirp.ID]; ok {
+			responses[i].Media = media
+		}
+		if chirp.PreviewUrl.Valid {
+			responses[i].Preview = previews[chirp.PreviewUrl.String]
+		}
+		responses[i].Poll = polls[chirp.ID]
+	}
+
+	return responses, nil
+
+}
+
+func (cfg *ApiConfig) buildChirpResponse(ctx context.Context, viewerID uuid.UUID, chirp database.Chirp) (chirpResponse, error) {
+
+	responses, err := cfg.buildChirpResponses(ctx, viewerID, []database.Chirp{chirp})
+	if err != nil {
+		return chirpResponse{}, err
+	}
+
+	return responses[0], nil
+
+}
+
+func convertChirpView(chirp chirpResponse) templates.ChirpView {
+
+	view := templates.ChirpView{
+		ID:        chirp.ID.String(),
+		Body:      chirp.Body,
+		CreatedAt: chirp.CreatedAt.Format("January 2, 2006 15:04"),
+		Edited:    chirp.Edited,
+	}
+
+	for _, medium := range chirp.Media {
+		view.Media = append(view.Media, templates.ChirpMediaView{
+			URL:          medium.URL,
+			ThumbnailURL: medium.ThumbnailURL,
+			AltText:      medium.AltText,
+		})
+	}
+
+	if chirp.Poll != nil {
+		poll := convertPollView(chirp.ID, chirp.Poll)
+		view.Poll = &poll
+	}
+
+	if chirp.Preview != nil {
+		view.Preview = &templates.ChirpPreviewView{
+			URL:         chirp.Preview.URL,
+			Title:       chirp.Preview.Title,
+			Description: chirp.Preview.Description,
+			ImageURL:    chirp.Preview.ImageURL,
+			SiteName:    chirp.Preview.SiteName,
+		}
+	}
+
+	if chirp.EditedAt != nil {
+		view.EditedAt = chirp.EditedAt.Format("January 2, 2006 15:04")
+	}
+
+	return view
+
 }
@@ -1,15 +1,47 @@
 package handler
 
 import (
+	"database/sql"
+	"net/http"
 	"sync/atomic"
+	"time"
 
+	"github.com/alexedwards/argon2id"
+	"github.com/sebasukodo/chirpy/internal/auth"
+	"github.com/sebasukodo/chirpy/internal/billing"
 	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/entitlements"
+	"github.com/sebasukodo/chirpy/internal/events"
+	"github.com/sebasukodo/chirpy/internal/jobs"
+	"github.com/sebasukodo/chirpy/internal/preview"
+	"github.com/sebasukodo/chirpy/internal/storage"
+	"github.com/sebasukodo/chirpy/internal/stream"
 )
 
 type ApiConfig struct {
 	FileserverHits atomic.Int32
+	DB             *sql.DB
 	DbQueries      *database.Queries
 	Platform       string
 	TokenSecret    string
-	PolkaApiKey    string
+	AdminApiKey    string
+	PasswordPolicy auth.PasswordPolicy
+	HashParams     *argon2id.Params
+
+	// BillingProviders are keyed by name, which is also used in webhook URLs.
+	BillingProviders map[string]billing.Provider
+	CheckoutProvider string
+
+	AccountGracePeriod time.Duration
+	ExportDir          string
+	ExportTTL          time.Duration
+	Plans              entitlements.Plans
+	Storage            storage.Storage
+	MediaMaxBytes      int64
+	MediaOrphanTTL     time.Duration
+	PreviewFetcher     *preview.Fetcher
+	WebhookClient      *http.Client
+	Events             events.Bus
+	Jobs               *jobs.Queue
+	ChirpHub           *stream.Hub
 }
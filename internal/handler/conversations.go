@@ -0,0 +1,579 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	MaxConversationMembers = 8
+	MaxMessageLength       = 1000
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 100
+)
+
+type conversationCreateRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids"`
+	Body    string      `json:"body"`
+}
+
+type messageCreateRequest struct {
+	Body string `json:"body"`
+}
+
+type conversationResponse struct {
+	ID          uuid.UUID                    `json:"id"`
+	CreatedAt   time.Time                    `json:"created_at"`
+	UpdatedAt   time.Time                    `json:"updated_at"`
+	IsGroup     bool                         `json:"is_group"`
+	Members     []conversationMemberResponse `json:"members"`
+	UnreadCount int64                        `json:"unread_count"`
+	LastMessage string                       `json:"last_message,omitempty"`
+}
+
+type conversationMemberResponse struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Handle      string    `json:"handle"`
+	Deactivated bool      `json:"deactivated"`
+}
+
+type messageResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	SenderID       *uuid.UUID `json:"sender_id"`
+	Body           string     `json:"body"`
+}
+
+type messagePageResponse struct {
+	Messages   []messageResponse `json:"messages"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// ConversationsCreate starts a conversation with the given users. One-to-one
+// conversations are unique per pair, starting one again returns the existing one.
+func (cfg *ApiConfig) ConversationsCreate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	req := conversationCreateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	others := slices.DeleteFunc(uniqueIDs(req.UserIDs), func(id uuid.UUID) bool { return id == userID })
+	if len(others) == 0 {
+		respondWithError(w, r, 400, "user_ids must contain at least one other user")
+		return
+	}
+
+	if len(others)+1 > MaxConversationMembers {
+		respondWithError(w, r, 400, fmt.Sprintf("a conversation can have at most %d members", MaxConversationMembers))
+		return
+	}
+
+	if req.Body != "" && len(req.Body) > MaxMessageLength {
+		respondWithError(w, r, 400, fmt.Sprintf("messages can be at most %d characters", MaxMessageLength))
+		return
+	}
+
+	active, err := cfg.DbQueries.CountActiveUsers(r.Context(), others)
+	if err != nil {
+		respondWithError(w, r, 500, "could not start conversation")
+		return
+	}
+	if active != int64(len(others)) {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	if blocked, err := cfg.hasBlockWith(r.Context(), userID, others); err != nil || blocked {
+		respondWithError(w, r, 403, "you cannot message this user")
+		return
+	}
+
+	params := database.CreateConversationParams{IsGroup: len(others) > 1}
+	if !params.IsGroup {
+		pair := []string{userID.String(), others[0].String()}
+		slices.Sort(pair)
+		params.DirectKey = sql.NullString{String: strings.Join(pair, ":"), Valid: true}
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not start conversation")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	conversation, err := queries.CreateConversation(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, 500, "could not start conversation")
+		return
+	}
+
+	for _, memberID := range append([]uuid.UUID{userID}, others...) {
+		if err := queries.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+			ConversationID: conversation.ID,
+			UserID:         memberID,
+		}); err != nil {
+			respondWithError(w, r, 500, "could not start conversation")
+			return
+		}
+	}
+
+	if req.Body != "" {
+		if _, err := storeMessage(r.Context(), queries, conversation.ID, userID, req.Body); err != nil {
+			respondWithError(w, r, 500, "could not send message")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not start conversation")
+		return
+	}
+
+	conversations, err := cfg.loadConversations(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve conversation")
+		return
+	}
+
+	for _, c := range conversations {
+		if c.ID == conversation.ID {
+			respondWithJSON(w, 201, c)
+			return
+		}
+	}
+
+	respondWithError(w, r, 500, "could not retrieve conversation")
+
+}
+
+func (cfg *ApiConfig) ConversationsList(w http.ResponseWriter, r *http.Request) {
+
+	conversations, err := cfg.loadConversations(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve conversations")
+		return
+	}
+
+	respondWithJSON(w, 200, conversations)
+
+}
+
+func (cfg *ApiConfig) ConversationsUnreadCount(w http.ResponseWriter, r *http.Request) {
+
+	unread, err := cfg.DbQueries.GetUnreadMessageCount(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not count unread messages")
+		return
+	}
+
+	respondWithJSON(w, 200, map[string]int64{"unread": unread})
+
+}
+
+func (cfg *ApiConfig) ConversationsMarkRead(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	conversationID, ok := cfg.conversationFromPath(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not mark conversation as read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// MessagesList pages backwards through a conversation, newest first. The
+// cursor is the id of the oldest message of the previous page.
+func (cfg *ApiConfig) MessagesList(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	conversationID, ok := cfg.conversationFromPath(w, r, userID)
+	if !ok {
+		return
+	}
+
+	pageSize, err := parsePageSize(r, DefaultMessagePageSize, MaxMessagePageSize)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	messages, err := cfg.messagesPage(r.Context(), conversationID, r.URL.Query().Get("before"), pageSize)
+	if errors.Is(err, errInvalidCursor) {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve messages")
+		return
+	}
+
+	response := messagePageResponse{Messages: make([]messageResponse, 0, len(messages))}
+	for _, message := range messages {
+		response.Messages = append(response.Messages, convertDatabaseMessage(message))
+	}
+
+	if len(messages) == pageSize {
+		response.NextCursor = messages[len(messages)-1].ID.String()
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) MessagesCreate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	conversationID, ok := cfg.conversationFromPath(w, r, userID)
+	if !ok {
+		return
+	}
+
+	req := messageCreateRequest{}
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, r, 400, "could not decode json message")
+			return
+		}
+	} else {
+		req.Body = r.FormValue("body")
+	}
+
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" || len(req.Body) > MaxMessageLength {
+		respondWithError(w, r, 400, fmt.Sprintf("messages must be between 1 and %d characters", MaxMessageLength))
+		return
+	}
+
+	members, err := cfg.DbQueries.GetConversationMembers(r.Context(), []uuid.UUID{conversationID})
+	if err != nil {
+		respondWithError(w, r, 500, "could not send message")
+		return
+	}
+
+	// Deactivated members keep their place in the conversation so it is
+	// intact if they restore their account, but nobody can write to them.
+	recipients := []uuid.UUID{}
+	for _, member := range members {
+		if member.UserID != userID && !member.DeletedAt.Valid {
+			recipients = append(recipients, member.UserID)
+		}
+	}
+
+	if len(recipients) == 0 {
+		respondWithError(w, r, 410, "conversation has no other active members")
+		return
+	}
+
+	if blocked, err := cfg.hasBlockWith(r.Context(), userID, recipients); err != nil || blocked {
+		respondWithError(w, r, 403, "you cannot message this conversation")
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not send message")
+		return
+	}
+	defer tx.Rollback()
+
+	message, err := storeMessage(r.Context(), cfg.DbQueries.WithTx(tx), conversationID, userID, req.Body)
+	if err != nil {
+		respondWithError(w, r, 500, "could not send message")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not send message")
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		handles := map[uuid.UUID]string{}
+		for _, member := range members {
+			handles[member.UserID] = member.Handle
+		}
+		respondWithHTML(templates.MessageItem(convertMessageView(message, userID, handles)), w, r)
+		return
+	}
+
+	respondWithJSON(w, 201, convertDatabaseMessage(message))
+
+}
+
+func (cfg *ApiConfig) InboxPage(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	conversations, err := cfg.loadConversations(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve conversations")
+		return
+	}
+
+	views := make([]templates.ConversationView, 0, len(conversations))
+	for _, conversation := range conversations {
+		views = append(views, convertConversationView(conversation, userID))
+	}
+
+	respondWithHTML(templates.Layout(templates.Inbox(views), "Inbox"), w, r)
+
+}
+
+func (cfg *ApiConfig) ConversationPage(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, r, 404, "conversation not found")
+		return
+	}
+
+	conversations, err := cfg.loadConversations(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve conversation")
+		return
+	}
+
+	index := slices.IndexFunc(conversations, func(c conversationResponse) bool { return c.ID == conversationID })
+	if index == -1 {
+		respondWithError(w, r, 404, "conversation not found")
+		return
+	}
+
+	messages, err := cfg.messagesPage(r.Context(), conversationID, "", DefaultMessagePageSize)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve messages")
+		return
+	}
+
+	handles := map[uuid.UUID]string{}
+	for _, member := range conversations[index].Members {
+		handles[member.UserID] = member.Handle
+	}
+
+	views := make([]templates.MessageView, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		views = append(views, convertMessageView(messages[i], userID, handles))
+	}
+
+	if err := cfg.DbQueries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not mark conversation as read")
+		return
+	}
+
+	view := convertConversationView(conversations[index], userID)
+	respondWithHTML(templates.Layout(templates.ConversationPage(view, views), view.Title), w, r)
+
+}
+
+// conversationFromPath parses the conversation id and checks that the user
+// is a member. Other users get a 404 so conversation ids are not confirmed.
+func (cfg *ApiConfig) conversationFromPath(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid conversation id")
+		return uuid.Nil, false
+	}
+
+	if _, err := cfg.DbQueries.GetConversationMember(r.Context(), database.GetConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	}); err != nil {
+		respondWithError(w, r, 404, "conversation not found")
+		return uuid.Nil, false
+	}
+
+	return conversationID, true
+
+}
+
+func (cfg *ApiConfig) messagesPage(ctx context.Context, conversationID uuid.UUID, before string, pageSize int) ([]database.Message, error) {
+
+	params := database.GetMessagesPageParams{
+		ConversationID:  conversationID,
+		BeforeCreatedAt: pageStart,
+		BeforeID:        uuid.Max,
+		PageSize:        int32(pageSize),
+	}
+
+	if before != "" {
+		cursorID, err := uuid.Parse(before)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+
+		cursor, err := cfg.DbQueries.GetMessageByID(ctx, cursorID)
+		if err != nil || cursor.ConversationID != conversationID {
+			return nil, errInvalidCursor
+		}
+
+		params.BeforeCreatedAt = cursor.CreatedAt
+		params.BeforeID = cursor.ID
+	}
+
+	return cfg.DbQueries.GetMessagesPage(ctx, params)
+
+}
+
+func (cfg *ApiConfig) loadConversations(ctx context.Context, userID uuid.UUID) ([]conversationResponse, error) {
+
+	rows, err := cfg.DbQueries.GetConversationsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]conversationResponse, 0, len(rows))
+	if len(rows) == 0 {
+		return response, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	members, err := cfg.DbQueries.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	membersByConversation := map[uuid.UUID][]conversationMemberResponse{}
+	for _, member := range members {
+		membersByConversation[member.ConversationID] = append(membersByConversation[member.ConversationID], conversationMemberResponse{
+			UserID:      member.UserID,
+			Handle:      member.Handle,
+			Deactivated: member.DeletedAt.Valid,
+		})
+	}
+
+	for _, row := range rows {
+		response = append(response, conversationResponse{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			IsGroup:     row.IsGroup,
+			Members:     membersByConversation[row.ID],
+			UnreadCount: row.UnreadCount,
+			LastMessage: row.LastMessage,
+		})
+	}
+
+	return response, nil
+
+}
+
+func storeMessage(ctx context.Context, queries *database.Queries, conversationID uuid.UUID, senderID uuid.UUID, body string) (database.Message, error) {
+
+	message, err := queries.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       uuid.NullUUID{UUID: senderID, Valid: true},
+		Body:           body,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+
+	if err := queries.TouchConversation(ctx, conversationID); err != nil {
+		return database.Message{}, err
+	}
+
+	// Sending a message implies having read the conversation up to it.
+	if err := queries.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         senderID,
+	}); err != nil {
+		return database.Message{}, err
+	}
+
+	return message, nil
+
+}
+
+func convertDatabaseMessage(message database.Message) messageResponse {
+
+	response := messageResponse{
+		ID:             message.ID,
+		CreatedAt:      message.CreatedAt,
+		ConversationID: message.ConversationID,
+		Body:           message.Body,
+	}
+
+	if message.SenderID.Valid {
+		response.SenderID = &message.SenderID.UUID
+	}
+
+	return response
+
+}
+
+func convertConversationView(conversation conversationResponse, viewerID uuid.UUID) templates.ConversationView {
+
+	names := []string{}
+	for _, member := range conversation.Members {
+		if member.UserID != viewerID {
+			names = append(names, userLabel(member.Handle, member.Deactivated))
+		}
+	}
+
+	return templates.ConversationView{
+		ID:          conversation.ID.String(),
+		Title:       strings.Join(names, ", "),
+		LastMessage: conversation.LastMessage,
+		UpdatedAt:   conversation.UpdatedAt.Format("January 2, 2006 15:04"),
+		Unread:      int(conversation.UnreadCount),
+	}
+
+}
+
+func convertMessageView(message database.Message, viewerID uuid.UUID, handles map[uuid.UUID]string) templates.MessageView {
+
+	view := templates.MessageView{
+		Body:      message.Body,
+		CreatedAt: message.CreatedAt.Format("January 2, 2006 15:04"),
+		Own:       message.SenderID.Valid && message.SenderID.UUID == viewerID,
+		Sender:    "deleted account",
+	}
+
+	if message.SenderID.Valid {
+		view.Sender = userLabel(handles[message.SenderID.UUID], false)
+	}
+
+	return view
+
+}
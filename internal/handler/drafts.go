@@ -0,0 +1,444 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
+	"github.com/sebasukodo/chirpy/internal/jobs"
+	"github.com/sebasukodo/chirpy/internal/visibility"
+)
+
+const (
+	DraftStatusDraft     = "draft"
+	DraftStatusScheduled = "scheduled"
+	DraftStatusPublished = "published"
+	DraftStatusFailed    = "failed"
+
+	MaxScheduleAhead = 365 * 24 * time.Hour
+	// DraftRateLimitDelay is how far a draft is pushed back when its author
+	// has reached the hourly chirp limit.
+	DraftRateLimitDelay = 10 * time.Minute
+)
+
+var errDraftRateLimited = errors.New("hourly chirp limit reached")
+
+type draftJob struct {
+	DraftID uuid.UUID `json:"draft_id"`
+}
+
+type draftRequest struct {
+	Body       string     `json:"body"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Visibility string     `json:"visibility"`
+}
+
+type draftResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+	Visibility string     `json:"visibility"`
+	Status     string     `json:"status"`
+	ChirpID    *uuid.UUID `json:"chirp_id,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func (cfg *ApiConfig) DraftsCreate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	req := draftRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	perks, err := cfg.userEntitlements(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	publishAt, status, err := validateDraft(&req, perks.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not create draft")
+		return
+	}
+	defer tx.Rollback()
+
+	draft, err := cfg.DbQueries.WithTx(tx).CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID:     userID,
+		Body:       req.Body,
+		PublishAt:  publishAt,
+		Status:     status,
+		Visibility: req.Visibility,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not create draft")
+		return
+	}
+
+	if err := cfg.scheduleDraft(r.Context(), tx, draft); err != nil {
+		respondWithError(w, r, 500, "could not create draft")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not create draft")
+		return
+	}
+
+	respondWithJSON(w, 201, convertDatabaseDraft(draft))
+
+}
+
+func (cfg *ApiConfig) DraftsList(w http.ResponseWriter, r *http.Request) {
+
+	drafts, err := cfg.DbQueries.GetDraftsForUser(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve drafts")
+		return
+	}
+
+	response := make([]draftResponse, 0, len(drafts))
+	for _, draft := range drafts {
+		response = append(response, convertDatabaseDraft(draft))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) DraftsGet(w http.ResponseWriter, r *http.Request) {
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid draft id")
+		return
+	}
+
+	draft, err := cfg.DbQueries.GetDraftByID(r.Context(), draftID)
+	if err != nil || draft.UserID != userIDFromContext(r.Context()) {
+		respondWithError(w, r, 404, "draft not found")
+		return
+	}
+
+	respondWithJSON(w, 200, convertDatabaseDraft(draft))
+
+}
+
+func (cfg *ApiConfig) DraftsUpdate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid draft id")
+		return
+	}
+
+	req := draftRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	perks, err := cfg.userEntitlements(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	publishAt, status, err := validateDraft(&req, perks.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not update draft")
+		return
+	}
+	defer tx.Rollback()
+
+	// Published drafts are excluded by the query. A draft that is being
+	// published right now stays locked until the scheduler commits.
+	draft, err := cfg.DbQueries.WithTx(tx).UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:         draftID,
+		UserID:     userID,
+		Body:       req.Body,
+		PublishAt:  publishAt,
+		Status:     status,
+		Visibility: req.Visibility,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, getErr := cfg.DbQueries.GetDraftByID(r.Context(), draftID)
+		if getErr == nil && existing.UserID == userID {
+			respondWithError(w, r, 409, "draft has already been published")
+			return
+		}
+		respondWithError(w, r, 404, "draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, "could not update draft")
+		return
+	}
+
+	if err := cfg.scheduleDraft(r.Context(), tx, draft); err != nil {
+		respondWithError(w, r, 500, "could not update draft")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not update draft")
+		return
+	}
+
+	respondWithJSON(w, 200, convertDatabaseDraft(draft))
+
+}
+
+func (cfg *ApiConfig) DraftsDelete(w http.ResponseWriter, r *http.Request) {
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid draft id")
+		return
+	}
+
+	deleted, err := cfg.DbQueries.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not delete draft")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, r, 404, "draft not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// validateDraft also defaults the visibility of req to public. Drafts have
+// no mentions, so they cannot be direct chirps.
+func validateDraft(req *draftRequest, maxLength int) (sql.NullTime, string, error) {
+
+	if _, err := validateChirpBody(req.Body, maxLength); err != nil {
+		return sql.NullTime{}, "", err
+	}
+
+	if req.Visibility == "" {
+		req.Visibility = visibility.Public
+	}
+
+	if !visibility.Valid(req.Visibility) || req.Visibility == visibility.Direct {
+		return sql.NullTime{}, "", fmt.Errorf("visibility must be one of public, followers or unlisted")
+	}
+
+	if req.PublishAt == nil {
+		return sql.NullTime{}, DraftStatusDraft, nil
+	}
+
+	publishAt := req.PublishAt.UTC()
+	now := time.Now().UTC()
+
+	if !publishAt.After(now) {
+		return sql.NullTime{}, "", fmt.Errorf("publish_at must be in the future")
+	}
+
+	if publishAt.After(now.Add(MaxScheduleAhead)) {
+		return sql.NullTime{}, "", fmt.Errorf("chirps can be scheduled at most a year ahead")
+	}
+
+	return sql.NullTime{Time: publishAt, Valid: true}, DraftStatusScheduled, nil
+
+}
+
+// scheduleDraft queues the job that publishes a scheduled draft. A draft that
+// is moved to another time gets another job, the old one finds the draft not
+// due and does nothing.
+func (cfg *ApiConfig) scheduleDraft(ctx context.Context, tx *sql.Tx, draft database.Draft) error {
+
+	if draft.Status != DraftStatusScheduled {
+		return nil
+	}
+
+	return cfg.Jobs.WithTx(tx).Enqueue(ctx, jobs.Job{
+		Kind:    JobPublishDraft,
+		Payload: draftJob{DraftID: draft.ID},
+		RunAt:   draft.PublishAt.Time,
+	})
+
+}
+
+// publishDraftJob turns a scheduled draft into a chirp. The draft is locked
+// and published in the same transaction that marks it as published, so it
+// becomes exactly one chirp even if its job runs twice.
+func (cfg *ApiConfig) publishDraftJob(ctx context.Context, payload []byte) error {
+
+	job := draftJob{}
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	draft, err := queries.ClaimDueDraft(ctx, job.DraftID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	body, err := cfg.moderateDraft(ctx, queries, draft)
+	if errors.Is(err, errDraftRateLimited) {
+		// The limit is temporary, so the draft stays scheduled.
+		draft.PublishAt = sql.NullTime{Time: time.Now().UTC().Add(DraftRateLimitDelay), Valid: true}
+		if err := queries.PostponeDraft(ctx, database.PostponeDraftParams{
+			ID:        draft.ID,
+			PublishAt: draft.PublishAt,
+			Error:     sql.NullString{String: err.Error(), Valid: true},
+		}); err != nil {
+			return err
+		}
+
+		if err := cfg.scheduleDraft(ctx, tx, draft); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+	if err != nil {
+		log.Printf("scheduled draft %v failed: %v", draft.ID, err)
+
+		if err := queries.MarkDraftFailed(ctx, database.MarkDraftFailedParams{
+			ID:    draft.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		}); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	chirp, err := queries.CreateChirp(ctx, database.CreateChirpParams{
+		Body:       body,
+		UserID:     draft.UserID,
+		PreviewUrl: previewURL(body),
+		Visibility: draft.Visibility,
+	})
+	if err != nil {
+		return err
+	}
+
+	if chirp.PreviewUrl.Valid {
+		if err := cfg.queueLinkPreview(ctx, tx, chirp.PreviewUrl.String); err != nil {
+			return err
+		}
+	}
+
+	if err := queries.MarkDraftPublished(ctx, database.MarkDraftPublishedParams{
+		ID:      draft.ID,
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	cfg.Events.Publish(ctx, events.Event{
+		Type:    events.TypeChirpCreated,
+		ActorID: chirp.UserID,
+		ChirpID: chirp.ID,
+	})
+
+	return nil
+
+}
+
+// moderateDraft repeats the checks of ChirpsCreate at publish time, since
+// limits or the author's account may have changed since scheduling.
+func (cfg *ApiConfig) moderateDraft(ctx context.Context, queries *database.Queries, draft database.Draft) (string, error) {
+
+	author, err := queries.GetUserByID(ctx, draft.UserID)
+	if err != nil {
+		return "", fmt.Errorf("could not load author: %w", err)
+	}
+
+	if author.DeletedAt.Valid {
+		return "", fmt.Errorf("author account is deactivated")
+	}
+
+	perks := cfg.entitlementsFor(author)
+
+	recent, err := queries.CountChirpsSince(ctx, database.CountChirpsSinceParams{
+		UserID:    draft.UserID,
+		CreatedAt: time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not count recent chirps: %w", err)
+	}
+
+	if recent >= int64(perks.ChirpsPerHour) {
+		return "", fmt.Errorf("%w, you can post at most %d chirps per hour", errDraftRateLimited, perks.ChirpsPerHour)
+	}
+
+	return validateChirpBody(draft.Body, perks.MaxChirpLength)
+
+}
+
+func convertDatabaseDraft(draft database.Draft) draftResponse {
+
+	response := draftResponse{
+		ID:         draft.ID,
+		CreatedAt:  draft.CreatedAt,
+		UpdatedAt:  draft.UpdatedAt,
+		Body:       draft.Body,
+		Visibility: draft.Visibility,
+		Status:     draft.Status,
+		Error:      draft.Error.String,
+	}
+
+	if draft.PublishAt.Valid {
+		response.PublishAt = &draft.PublishAt.Time
+	}
+
+	if draft.ChirpID.Valid {
+		response.ChirpID = &draft.ChirpID.UUID
+	}
+
+	return response
+
+}
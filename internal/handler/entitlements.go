@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/entitlements"
+)
+
+// entitlementsFor is the only place that decides which plan a user is on.
+func (cfg *ApiConfig) entitlementsFor(user database.User) entitlements.Entitlements {
+
+	plan := entitlements.PlanFree
+	if user.IsChirpyRed {
+		plan = entitlements.PlanRed
+	}
+
+	return cfg.Plans.For(plan)
+
+}
+
+func (cfg *ApiConfig) userEntitlements(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+
+	user, err := cfg.DbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+
+	return cfg.entitlementsFor(user), nil
+
+}
@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/archive"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/jobs"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+type dataExportResponse struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Status      string    `json:"status"`
+	DownloadURL string    `json:"download_url,omitempty"`
+}
+
+func (cfg *ApiConfig) UsersExportCreate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not create export")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	// Locking the user keeps two requests from both passing the check.
+	if _, err := queries.LockUserByID(r.Context(), userID); err != nil {
+		respondWithError(w, r, 500, "could not create export")
+		return
+	}
+
+	open, err := queries.CountOpenDataExports(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not create export")
+		return
+	}
+
+	if open > 0 {
+		respondWithError(w, r, 409, "an export is already pending or ready for download")
+		return
+	}
+
+	export, err := queries.CreateDataExport(r.Context(), database.CreateDataExportParams{
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(cfg.ExportTTL),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not create export")
+		return
+	}
+
+	if err := cfg.Jobs.WithTx(tx).Enqueue(r.Context(), jobs.Job{
+		Kind:      JobBuildDataExport,
+		Payload:   dataExportJob{ExportID: export.ID},
+		UniqueKey: export.ID.String(),
+	}); err != nil {
+		respondWithError(w, r, 500, "could not queue export")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not create export")
+		return
+	}
+
+	cfg.recordAccountEvent(r.Context(), userID, AccountEventExportRequested, "")
+
+	if r.Header.Get("HX-Request") == "true" {
+		cfg.respondWithExportList(w, r, userID)
+		return
+	}
+
+	respondWithJSON(w, 202, convertDatabaseExport(export))
+
+}
+
+func (cfg *ApiConfig) UsersExportList(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	if r.Header.Get("HX-Request") == "true" {
+		cfg.respondWithExportList(w, r, userID)
+		return
+	}
+
+	exports, err := cfg.DbQueries.GetDataExportsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve exports")
+		return
+	}
+
+	response := make([]dataExportResponse, 0, len(exports))
+	for _, export := range exports {
+		response = append(response, convertDatabaseExport(export))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) UsersExportDownload(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid export id")
+		return
+	}
+
+	export, err := cfg.DbQueries.GetDataExportByID(r.Context(), exportID)
+	if err != nil || export.UserID != userID {
+		respondWithError(w, r, 404, "export not found")
+		return
+	}
+
+	if export.Status != ExportStatusReady || !export.FilePath.Valid || export.ExpiresAt.Before(time.Now().UTC()) {
+		respondWithError(w, r, 404, "export not available")
+		return
+	}
+
+	file, err := os.Open(export.FilePath.String)
+	if err != nil {
+		respondWithError(w, r, 404, "export not available")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.CreatedAt.Format("2006-01-02")))
+
+	http.ServeContent(w, r, "", export.UpdatedAt, file)
+
+}
+
+func (cfg *ApiConfig) BuildDataExport(ctx context.Context, export database.DataExport) {
+
+	path, err := cfg.writeDataExport(ctx, export)
+	if err != nil {
+		log.Printf("data export %v failed: %v", export.ID, err)
+		if err := cfg.DbQueries.SetDataExportFailed(ctx, database.SetDataExportFailedParams{
+			ID:    export.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		}); err != nil {
+			log.Printf("could not mark data export %v as failed: %v", export.ID, err)
+		}
+		return
+	}
+
+	if err := cfg.DbQueries.SetDataExportReady(ctx, database.SetDataExportReadyParams{
+		ID:       export.ID,
+		FilePath: sql.NullString{String: path, Valid: true},
+	}); err != nil {
+		log.Printf("could not mark data export %v as ready: %v", export.ID, err)
+	}
+
+}
+
+func (cfg *ApiConfig) CleanupExpiredExports(ctx context.Context) error {
+
+	exports, err := cfg.DbQueries.GetExpiredDataExports(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if export.FilePath.Valid {
+			if err := os.Remove(export.FilePath.String); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		if err := cfg.DbQueries.DeleteDataExportByID(ctx, export.ID); err != nil {
+			return err
+		}
+	}
+
+	// Purged accounts take their export rows with them, so remove any
+	// archive that no longer has a row.
+	files, err := filepath.Glob(filepath.Join(cfg.ExportDir, "*.zip"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		exportID, err := uuid.Parse(strings.TrimSuffix(filepath.Base(file), ".zip"))
+		if err != nil {
+			continue
+		}
+
+		if _, err := cfg.DbQueries.GetDataExportByID(ctx, exportID); errors.Is(err, sql.ErrNoRows) {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+
+}
+
+func (cfg *ApiConfig) writeDataExport(ctx context.Context, export database.DataExport) (string, error) {
+
+	user, err := cfg.DbQueries.GetUserByID(ctx, export.UserID)
+	if err != nil {
+		return "", fmt.Errorf("could not load user: %w", err)
+	}
+
+	chirps, err := cfg.DbQueries.GetAllChirpsFromAuthor(ctx, export.UserID)
+	if err != nil {
+		return "", fmt.Errorf("could not load chirps: %w", err)
+	}
+
+	sessions, err := cfg.DbQueries.GetSessionsForUser(ctx, export.UserID)
+	if err != nil {
+		return "", fmt.Errorf("could not load sessions: %w", err)
+	}
+
+	events, err := cfg.DbQueries.GetAccountEventsForUser(ctx, export.UserID)
+	if err != nil {
+		return "", fmt.Errorf("could not load account history: %w", err)
+	}
+
+	data := archive.Archive{
+		Manifest: archive.Manifest{
+			ExportedAt: time.Now().UTC(),
+			UserID:     user.ID,
+		},
+		Profile: archive.Profile{
+			ID:          user.ID,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+			Email:       user.Email,
+			IsChirpyRed: user.IsChirpyRed,
+		},
+	}
+
+	for _, chirp := range chirps {
+		data.Chirps = append(data.Chirps, archive.Chirp{
+			ID:         chirp.ID,
+			CreatedAt:  chirp.CreatedAt,
+			UpdatedAt:  chirp.UpdatedAt,
+			Body:       chirp.Body,
+			Visibility: chirp.Visibility,
+		})
+	}
+
+	for _, session := range sessions {
+		exported := archive.Session{
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		}
+		if session.RevokedAt.Valid {
+			exported.RevokedAt = &session.RevokedAt.Time
+		}
+		data.Sessions = append(data.Sessions, exported)
+	}
+
+	for _, event := range events {
+		data.History = append(data.History, archive.AccountEvent{
+			CreatedAt: event.CreatedAt,
+			Event:     event.Event,
+			Detail:    event.Detail,
+		})
+	}
+
+	if err := os.MkdirAll(cfg.ExportDir, 0o700); err != nil {
+		return "", fmt.Errorf("could not create export directory: %w", err)
+	}
+
+	path := filepath.Join(cfg.ExportDir, export.ID.String()+".zip")
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("could not create export file: %w", err)
+	}
+	defer file.Close()
+
+	if err := archive.Write(file, data); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+
+}
+
+func (cfg *ApiConfig) respondWithExportList(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+
+	exports, err := cfg.DbQueries.GetDataExportsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve exports")
+		return
+	}
+
+	respondWithHTML(templates.DataExports(convertExportViews(exports)), w, r)
+
+}
+
+func convertExportViews(exports []database.DataExport) []templates.DataExportView {
+
+	views := make([]templates.DataExportView, 0, len(exports))
+	for _, export := range exports {
+		response := convertDatabaseExport(export)
+		views = append(views, templates.DataExportView{
+			Status:      response.Status,
+			CreatedAt:   response.CreatedAt.Format("January 2, 2006 15:04"),
+			ExpiresAt:   response.ExpiresAt.Format("January 2, 2006 15:04"),
+			DownloadURL: response.DownloadURL,
+		})
+	}
+
+	return views
+
+}
+
+func convertDatabaseExport(export database.DataExport) dataExportResponse {
+
+	response := dataExportResponse{
+		ID:        export.ID,
+		CreatedAt: export.CreatedAt,
+		ExpiresAt: export.ExpiresAt,
+		Status:    export.Status,
+	}
+
+	if export.Status == ExportStatusReady && export.ExpiresAt.After(time.Now().UTC()) {
+		response.DownloadURL = "/api/users/me/exports/" + export.ID.String()
+	}
+
+	return response
+
+}
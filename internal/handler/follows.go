@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
+)
+
+func (cfg *ApiConfig) UsersFollow(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	if followeeID == userID {
+		respondWithError(w, r, 400, "you cannot follow yourself")
+		return
+	}
+
+	followee, err := cfg.DbQueries.GetUserByID(r.Context(), followeeID)
+	if err != nil || followee.DeletedAt.Valid {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	if blocked, err := cfg.hasBlockWith(r.Context(), userID, []uuid.UUID{followeeID}); err != nil || blocked {
+		respondWithError(w, r, 403, "you cannot follow this user")
+		return
+	}
+
+	followed, err := cfg.DbQueries.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not follow user")
+		return
+	}
+
+	if followed > 0 {
+		cfg.Events.Publish(r.Context(), events.Event{
+			Type:    events.TypeUserFollowed,
+			ActorID: userID,
+			UserID:  followeeID,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) UsersUnfollow(w http.ResponseWriter, r *http.Request) {
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	if err := cfg.DbQueries.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userIDFromContext(r.Context()),
+		FolloweeID: followeeID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not unfollow user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// ChirpsFeed lists the newest chirps of the user and the accounts they follow.
+func (cfg *ApiConfig) ChirpsFeed(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	chirps, err := cfg.DbQueries.GetFeedChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve feed")
+		return
+	}
+
+	chirps, err = cfg.visibleChirps(r.Context(), userID, chirps, true)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve feed")
+		return
+	}
+
+	response, err := cfg.buildChirpResponses(r.Context(), userID, chirps)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve feed")
+		return
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
@@ -0,0 +1,342 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/entitlements"
+	"github.com/sebasukodo/chirpy/internal/importer"
+	"github.com/sebasukodo/chirpy/internal/jobs"
+	"github.com/sebasukodo/chirpy/internal/visibility"
+)
+
+const (
+	ImportMaxBytes        = 64 << 20
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusCancelled = "cancelled"
+)
+
+type importResponse struct {
+	ID             uuid.UUID             `json:"id"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+	Source         string                `json:"source"`
+	LongBodyPolicy string                `json:"long_body_policy"`
+	Status         string                `json:"status"`
+	Total          int32                 `json:"total"`
+	Processed      int32                 `json:"processed"`
+	Imported       int32                 `json:"imported"`
+	Skipped        int32                 `json:"skipped"`
+	Failed         int32                 `json:"failed"`
+	Errors         []importErrorResponse `json:"errors"`
+}
+
+type importJob struct {
+	ImportID uuid.UUID `json:"import_id"`
+}
+
+type importErrorResponse struct {
+	Item    string `json:"item"`
+	Message string `json:"message"`
+}
+
+func (cfg *ApiConfig) UsersImportCreate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	r.Body = http.MaxBytesReader(w, r.Body, ImportMaxBytes)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, r, 400, "file is required")
+		return
+	}
+	defer file.Close()
+
+	policy := r.FormValue("long_body_policy")
+	if policy == "" {
+		policy = importer.PolicyReject
+	}
+
+	if !importer.ValidPolicy(policy) {
+		respondWithError(w, r, 400, "long_body_policy must be one of reject, truncate, split or skip")
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondWithError(w, r, 400, "could not read file")
+		return
+	}
+
+	source, items, err := importer.Parse(data)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	imp, err := cfg.StartImport(r.Context(), userID, source, policy, items, true)
+	if err != nil {
+		respondWithError(w, r, 500, "could not start import")
+		return
+	}
+
+	respondWithJSON(w, 202, convertDatabaseImport(imp, nil))
+
+}
+
+func (cfg *ApiConfig) UsersImportGet(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	importID, err := uuid.Parse(r.PathValue("importID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid import id")
+		return
+	}
+
+	imp, err := cfg.DbQueries.GetImportByID(r.Context(), importID)
+	if err != nil || imp.UserID != userID {
+		respondWithError(w, r, 404, "import not found")
+		return
+	}
+
+	importErrors, err := cfg.DbQueries.GetImportErrors(r.Context(), importID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve import errors")
+		return
+	}
+
+	respondWithJSON(w, 200, convertDatabaseImport(imp, importErrors))
+
+}
+
+// StartImport saves the items with the import, so an interrupted import can
+// be resumed. With background set a job runs the import, otherwise the caller
+// runs it with RunImport.
+func (cfg *ApiConfig) StartImport(ctx context.Context, userID uuid.UUID, source string, policy string, items []importer.Item, background bool) (database.Import, error) {
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return database.Import{}, err
+	}
+
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Import{}, err
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	imp, err := queries.CreateImport(ctx, database.CreateImportParams{
+		UserID:         userID,
+		Source:         source,
+		LongBodyPolicy: policy,
+		Total:          int32(len(items)),
+	})
+	if err != nil {
+		return database.Import{}, err
+	}
+
+	if err := queries.CreateImportItems(ctx, database.CreateImportItemsParams{
+		ImportID: imp.ID,
+		Items:    string(data),
+	}); err != nil {
+		return database.Import{}, err
+	}
+
+	if background {
+		if err := cfg.Jobs.WithTx(tx).Enqueue(ctx, jobs.Job{
+			Kind:      JobRunImport,
+			Payload:   importJob{ImportID: imp.ID},
+			UniqueKey: imp.ID.String(),
+		}); err != nil {
+			return database.Import{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.Import{}, err
+	}
+
+	return imp, nil
+
+}
+
+// CancelImport gives up on an interrupted import.
+func (cfg *ApiConfig) CancelImport(ctx context.Context, importID uuid.UUID) error {
+	return cfg.finishImport(ctx, importID, ImportStatusCancelled)
+}
+
+// RunImport stores the items as chirps of the importing user, keeping their
+// original timestamps, and persists progress after every item so it can be
+// polled and resumed. It picks up after the items the import already
+// processed and returns an error if it is interrupted before the end.
+func (cfg *ApiConfig) RunImport(ctx context.Context, imp database.Import, items []importer.Item, report importer.ReportFunc) (importer.Progress, error) {
+
+	store := func(ctx context.Context, item importer.Item, body string, createdAt time.Time) error {
+		// Mentions are not part of archives, so direct chirps come back
+		// visible to their author only.
+		level := item.Visibility
+		if !visibility.Valid(level) {
+			level = visibility.Public
+		}
+
+		_, err := cfg.DbQueries.ImportChirp(ctx, database.ImportChirpParams{
+			Body:       body,
+			UserID:     imp.UserID,
+			CreatedAt:  createdAt.UTC(),
+			Visibility: level,
+		})
+		return err
+	}
+
+	saveProgress := func(progress importer.Progress) {
+		if err := cfg.DbQueries.UpdateImportProgress(ctx, database.UpdateImportProgressParams{
+			ID:        imp.ID,
+			Processed: int32(progress.Processed),
+			Imported:  int32(progress.Imported),
+			Skipped:   int32(progress.Skipped),
+			Failed:    int32(progress.Failed),
+		}); err != nil {
+			log.Printf("could not save progress of import %v: %v", imp.ID, err)
+		}
+	}
+
+	perks, err := cfg.userEntitlements(ctx, imp.UserID)
+	if err != nil {
+		log.Printf("could not load entitlements for import %v: %v", imp.ID, err)
+		perks = cfg.Plans.For(entitlements.PlanFree)
+	}
+
+	progress := importer.Run(ctx, items, importer.Options{
+		MaxLength: perks.MaxChirpLength,
+		Policy:    imp.LongBodyPolicy,
+		Clean:     removeSlurs,
+		Resume: importer.Progress{
+			Processed: int(imp.Processed),
+			Imported:  int(imp.Imported),
+			Skipped:   int(imp.Skipped),
+			Failed:    int(imp.Failed),
+		},
+	}, store, func(progress importer.Progress, itemErr *importer.ItemError) {
+		if itemErr != nil {
+			if err := cfg.DbQueries.CreateImportError(ctx, database.CreateImportErrorParams{
+				ImportID: imp.ID,
+				ItemRef:  itemErr.Ref,
+				Message:  itemErr.Message,
+			}); err != nil {
+				log.Printf("could not record error of import %v: %v", imp.ID, err)
+			}
+		}
+
+		saveProgress(progress)
+
+		if report != nil {
+			report(progress, itemErr)
+		}
+	})
+
+	if progress.Processed < progress.Total {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		return progress, errors.New("import interrupted")
+	}
+
+	if err := cfg.finishImport(context.WithoutCancel(ctx), imp.ID, ImportStatusCompleted); err != nil {
+		log.Printf("could not finish import %v: %v", imp.ID, err)
+	}
+
+	return progress, nil
+
+}
+
+// runImportJob resumes the import where it stopped. An interrupted run fails
+// the job, so the import goes on when the job is retried.
+func (cfg *ApiConfig) runImportJob(ctx context.Context, payload []byte) error {
+
+	job := importJob{}
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	imp, err := cfg.DbQueries.GetImportByID(ctx, job.ImportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if imp.Status != ImportStatusRunning {
+		return nil
+	}
+
+	data, err := cfg.DbQueries.GetImportItems(ctx, imp.ID)
+	if err != nil {
+		return err
+	}
+
+	items := []importer.Item{}
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		return err
+	}
+
+	_, err = cfg.RunImport(ctx, imp, items, nil)
+	return err
+
+}
+
+// finishImport sets the final status and drops the saved items, which are a
+// copy of the user's posts.
+func (cfg *ApiConfig) finishImport(ctx context.Context, importID uuid.UUID, status string) error {
+
+	if err := cfg.DbQueries.SetImportStatus(ctx, database.SetImportStatusParams{
+		ID:     importID,
+		Status: status,
+	}); err != nil {
+		return err
+	}
+
+	return cfg.DbQueries.DeleteImportItems(ctx, importID)
+
+}
+
+func convertDatabaseImport(imp database.Import, importErrors []database.ImportError) importResponse {
+
+	response := importResponse{
+		ID:             imp.ID,
+		CreatedAt:      imp.CreatedAt,
+		UpdatedAt:      imp.UpdatedAt,
+		Source:         imp.Source,
+		LongBodyPolicy: imp.LongBodyPolicy,
+		Status:         imp.Status,
+		Total:          imp.Total,
+		Processed:      imp.Processed,
+		Imported:       imp.Imported,
+		Skipped:        imp.Skipped,
+		Failed:         imp.Failed,
+		Errors:         make([]importErrorResponse, 0, len(importErrors)),
+	}
+
+	for _, importErr := range importErrors {
+		response.Errors = append(response.Errors, importErrorResponse{
+			Item:    importErr.ItemRef,
+			Message: importErr.Message,
+		})
+	}
+
+	return response
+
+}
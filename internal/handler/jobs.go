@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+)
+
+const (
+	JobBuildDataExport  = "build_data_export"
+	JobRunImport        = "run_import"
+	JobFetchLinkPreview = "fetch_link_preview"
+	JobPublishDraft     = "publish_draft"
+	JobDeliverWebhook   = "deliver_webhook"
+
+	// Finished jobs keep their unique key until they are pruned, dead ones
+	// are kept longer so they can be looked into.
+	JobDoneRetention = 24 * time.Hour
+	JobDeadRetention = 7 * 24 * time.Hour
+)
+
+type dataExportJob struct {
+	ExportID uuid.UUID `json:"export_id"`
+}
+
+type jobResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Kind        string     `json:"kind"`
+	Payload     string     `json:"payload"`
+	UniqueKey   string     `json:"unique_key,omitempty"`
+	Status      string     `json:"status"`
+	Attempts    int32      `json:"attempts"`
+	MaxAttempts int32      `json:"max_attempts"`
+	RunAt       time.Time  `json:"run_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// buildDataExportJob builds an export unless it is gone or already built, so
+// a job picked up again after a crash does no harm.
+func (cfg *ApiConfig) buildDataExportJob(ctx context.Context, payload []byte) error {
+
+	job := dataExportJob{}
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	export, err := cfg.DbQueries.GetDataExportByID(ctx, job.ExportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if export.Status != ExportStatusPending {
+		return nil
+	}
+
+	cfg.BuildDataExport(ctx, export)
+
+	return nil
+
+}
+
+func (cfg *ApiConfig) PruneJobs(ctx context.Context) error {
+
+	now := time.Now().UTC()
+
+	pruned, err := cfg.DbQueries.DeleteFinishedJobs(ctx, database.DeleteFinishedJobsParams{
+		DoneBefore: sql.NullTime{Time: now.Add(-JobDoneRetention), Valid: true},
+		DeadBefore: sql.NullTime{Time: now.Add(-JobDeadRetention), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	if pruned > 0 {
+		log.Printf("pruned %d finished jobs", pruned)
+	}
+
+	return nil
+
+}
+
+func (cfg *ApiConfig) AdminJobs(w http.ResponseWriter, r *http.Request) {
+
+	pageSize, err := parsePageSize(r, 50, 500)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	jobs, err := cfg.DbQueries.GetRecentJobs(r.Context(), database.GetRecentJobsParams{
+		Status:   r.URL.Query().Get("status"),
+		PageSize: int32(pageSize),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve jobs")
+		return
+	}
+
+	response := make([]jobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		response = append(response, convertJob(job))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+// AdminJobRetry queues a dead job again with a fresh set of attempts.
+func (cfg *ApiConfig) AdminJobRetry(w http.ResponseWriter, r *http.Request) {
+
+	jobID, err := uuid.Parse(r.PathValue("jobID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid job id")
+		return
+	}
+
+	job, err := cfg.DbQueries.RequeueDeadJob(r.Context(), jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 404, "no dead job with this id")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, "could not retry job")
+		return
+	}
+
+	respondWithJSON(w, 200, convertJob(job))
+
+}
+
+func convertJob(job database.Job) jobResponse {
+	return jobResponse{
+		ID:          job.ID,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		UniqueKey:   job.UniqueKey.String,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		LastError:   job.LastError,
+		FinishedAt:  nullTimePtr(job.FinishedAt),
+	}
+}
@@ -0,0 +1,446 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	MaxListNameLength        = 50
+	MaxListDescriptionLength = 200
+)
+
+type listRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+type listMemberRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type listResponse struct {
+	ID          uuid.UUID   `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	UserID      uuid.UUID   `json:"user_id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Private     bool        `json:"private"`
+	MemberIDs   []uuid.UUID `json:"member_ids,omitempty"`
+}
+
+func (cfg *ApiConfig) ListsCreate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	req := listRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	name, description, err := validateList(req)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	user, err := cfg.DbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	count, err := cfg.DbQueries.CountListsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not create list")
+		return
+	}
+
+	if limit := cfg.entitlementsFor(user).MaxLists; count >= int64(limit) {
+		respondWithError(w, r, 403, fmt.Sprintf("you can have at most %d lists", limit))
+		return
+	}
+
+	list, err := cfg.DbQueries.CreateList(r.Context(), database.CreateListParams{
+		UserID:      userID,
+		Name:        name,
+		Description: description,
+		IsPrivate:   req.Private,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not create list")
+		return
+	}
+
+	respondWithJSON(w, 201, convertDatabaseList(list, nil))
+
+}
+
+func (cfg *ApiConfig) ListsList(w http.ResponseWriter, r *http.Request) {
+
+	lists, err := cfg.DbQueries.GetListsForUser(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve lists")
+		return
+	}
+
+	response := make([]listResponse, 0, len(lists))
+	for _, list := range lists {
+		response = append(response, convertDatabaseList(list, nil))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) ListsGet(w http.ResponseWriter, r *http.Request) {
+
+	list, ok := cfg.listFromPath(w, r, false)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.DbQueries.GetListMembers(r.Context(), list.ID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve list")
+		return
+	}
+
+	respondWithJSON(w, 200, convertDatabaseList(list, members))
+
+}
+
+func (cfg *ApiConfig) ListsUpdate(w http.ResponseWriter, r *http.Request) {
+
+	list, ok := cfg.listFromPath(w, r, true)
+	if !ok {
+		return
+	}
+
+	req := listRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	name, description, err := validateList(req)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	updated, err := cfg.DbQueries.UpdateList(r.Context(), database.UpdateListParams{
+		ID:          list.ID,
+		UserID:      list.UserID,
+		Name:        name,
+		Description: description,
+		IsPrivate:   req.Private,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not update list")
+		return
+	}
+
+	respondWithJSON(w, 200, convertDatabaseList(updated, nil))
+
+}
+
+func (cfg *ApiConfig) ListsDelete(w http.ResponseWriter, r *http.Request) {
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid list id")
+		return
+	}
+
+	deleted, err := cfg.DbQueries.DeleteList(r.Context(), database.DeleteListParams{
+		ID:     listID,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not delete list")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, r, 404, "list not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) ListMembersAdd(w http.ResponseWriter, r *http.Request) {
+
+	list, ok := cfg.listFromPath(w, r, true)
+	if !ok {
+		return
+	}
+
+	req := listMemberRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	member, err := cfg.DbQueries.GetUserByID(r.Context(), req.UserID)
+	if err != nil || member.DeletedAt.Valid {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	if blocked, err := cfg.hasBlockWith(r.Context(), list.UserID, []uuid.UUID{member.ID}); err != nil || blocked {
+		respondWithError(w, r, 403, "you cannot add this user")
+		return
+	}
+
+	owner, err := cfg.DbQueries.GetUserByID(r.Context(), list.UserID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	count, err := cfg.DbQueries.CountListMembers(r.Context(), list.ID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not add list member")
+		return
+	}
+
+	if limit := cfg.entitlementsFor(owner).MaxListMembers; count >= int64(limit) {
+		respondWithError(w, r, 403, fmt.Sprintf("a list can have at most %d members", limit))
+		return
+	}
+
+	if _, err := cfg.DbQueries.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: member.ID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not add list member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) ListMembersRemove(w http.ResponseWriter, r *http.Request) {
+
+	list, ok := cfg.listFromPath(w, r, true)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	if err := cfg.DbQueries.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: memberID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not remove list member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// ListChirps is the timeline of a list: the newest chirps of its members
+// the viewer can see. The cursor is the id of the last chirp.
+func (cfg *ApiConfig) ListChirps(w http.ResponseWriter, r *http.Request) {
+
+	list, ok := cfg.listFromPath(w, r, false)
+	if !ok {
+		return
+	}
+
+	pageSize, err := parsePageSize(r, DefaultChirpPageSize, MaxChirpPageSize)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	page, err := cfg.listChirpsPage(r.Context(), userIDFromContext(r.Context()), list.ID, r.URL.Query().Get("before"), pageSize)
+	if errors.Is(err, errInvalidCursor) {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve list chirps")
+		return
+	}
+
+	respondWithJSON(w, 200, page)
+
+}
+
+func (cfg *ApiConfig) ListsPage(w http.ResponseWriter, r *http.Request) {
+
+	lists, err := cfg.DbQueries.GetListsForUser(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve lists")
+		return
+	}
+
+	views := make([]templates.ListView, 0, len(lists))
+	for _, list := range lists {
+		views = append(views, convertListView(list))
+	}
+
+	respondWithHTML(templates.Layout(templates.Lists(views), "Lists"), w, r)
+
+}
+
+func (cfg *ApiConfig) ListPage(w http.ResponseWriter, r *http.Request) {
+
+	viewerID := userIDFromContext(r.Context())
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, r, 404, "list not found")
+		return
+	}
+
+	list, err := cfg.DbQueries.GetListByID(r.Context(), listID)
+	if err != nil || (list.IsPrivate && list.UserID != viewerID) {
+		respondWithError(w, r, 404, "list not found")
+		return
+	}
+
+	page, err := cfg.listChirpsPage(r.Context(), viewerID, list.ID, "", MaxChirpPageSize)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve list chirps")
+		return
+	}
+
+	respondWithHTML(templates.Layout(templates.ListPage(convertListView(list), convertChirpViews(page.Chirps)), list.Name), w, r)
+
+}
+
+// listFromPath loads the list of the path. Private lists are only found by
+// their owner, and with ownerOnly set so are all others.
+func (cfg *ApiConfig) listFromPath(w http.ResponseWriter, r *http.Request, ownerOnly bool) (database.List, bool) {
+
+	viewerID := userIDFromContext(r.Context())
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid list id")
+		return database.List{}, false
+	}
+
+	list, err := cfg.DbQueries.GetListByID(r.Context(), listID)
+	if err != nil {
+		respondWithError(w, r, 404, "list not found")
+		return database.List{}, false
+	}
+
+	if (ownerOnly || list.IsPrivate) && list.UserID != viewerID {
+		respondWithError(w, r, 404, "list not found")
+		return database.List{}, false
+	}
+
+	return list, true
+
+}
+
+func (cfg *ApiConfig) listChirpsPage(ctx context.Context, viewerID uuid.UUID, listID uuid.UUID, before string, pageSize int) (chirpPageResponse, error) {
+
+	params := database.GetListChirpsParams{
+		ListID:          listID,
+		BeforeCreatedAt: pageStart,
+		BeforeID:        uuid.Max,
+		PageSize:        int32(pageSize),
+	}
+
+	if before != "" {
+		cursorID, err := uuid.Parse(before)
+		if err != nil {
+			return chirpPageResponse{}, errInvalidCursor
+		}
+
+		cursor, err := cfg.DbQueries.GetChirpByID(ctx, cursorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return chirpPageResponse{}, errInvalidCursor
+		}
+		if err != nil {
+			return chirpPageResponse{}, err
+		}
+
+		params.BeforeCreatedAt = cursor.CreatedAt
+		params.BeforeID = cursor.ID
+	}
+
+	chirps, err := cfg.DbQueries.GetListChirps(ctx, params)
+	if err != nil {
+		return chirpPageResponse{}, err
+	}
+
+	return cfg.chirpPage(ctx, viewerID, chirps, pageSize, true)
+
+}
+
+func validateList(req listRequest) (string, string, error) {
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > MaxListNameLength {
+		return "", "", fmt.Errorf("name must be between 1 and %d characters", MaxListNameLength)
+	}
+
+	description := strings.TrimSpace(req.Description)
+	if len(description) > MaxListDescriptionLength {
+		return "", "", fmt.Errorf("description can be at most %d characters", MaxListDescriptionLength)
+	}
+
+	return name, description, nil
+
+}
+
+func convertDatabaseList(list database.List, members []database.ListMember) listResponse {
+
+	response := listResponse{
+		ID:          list.ID,
+		CreatedAt:   list.CreatedAt,
+		UpdatedAt:   list.UpdatedAt,
+		UserID:      list.UserID,
+		Name:        list.Name,
+		Description: list.Description,
+		Private:     list.IsPrivate,
+	}
+
+	if members != nil {
+		response.MemberIDs = make([]uuid.UUID, 0, len(members))
+		for _, member := range members {
+			response.MemberIDs = append(response.MemberIDs, member.UserID)
+		}
+	}
+
+	return response
+
+}
+
+func convertListView(list database.List) templates.ListView {
+	return templates.ListView{
+		ID:          list.ID.String(),
+		Name:        list.Name,
+		Description: list.Description,
+		Private:     list.IsPrivate,
+	}
+}
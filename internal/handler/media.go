@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/media"
+	"github.com/sebasukodo/chirpy/internal/storage"
+	"github.com/sebasukodo/chirpy/internal/visibility"
+)
+
+const (
+	MaxMediaPerChirp = 4
+	MaxAltTextLength = 1000
+)
+
+type mediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	AltText      string    `json:"alt_text"`
+}
+
+type mediaUpdateRequest struct {
+	AltText string `json:"alt_text"`
+}
+
+func (cfg *ApiConfig) MediaUpload(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	// Leave some room for the multipart framing and the alt text.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MediaMaxBytes+64<<10)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondWithError(w, r, 413, fmt.Sprintf("file is larger than %d bytes", cfg.MediaMaxBytes))
+			return
+		}
+		respondWithError(w, r, 400, "file is required")
+		return
+	}
+	defer file.Close()
+
+	altText := r.FormValue("alt_text")
+	if len(altText) > MaxAltTextLength {
+		respondWithError(w, r, 400, fmt.Sprintf("alt text is longer than %d characters", MaxAltTextLength))
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, cfg.MediaMaxBytes+1))
+	if err != nil {
+		respondWithError(w, r, 400, "could not read file")
+		return
+	}
+
+	if int64(len(data)) > cfg.MediaMaxBytes {
+		respondWithError(w, r, 413, fmt.Sprintf("file is larger than %d bytes", cfg.MediaMaxBytes))
+		return
+	}
+
+	img, err := media.Process(data)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupportedType) {
+			respondWithError(w, r, 415, err.Error())
+			return
+		}
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	mediaID := uuid.New()
+	storageKey := "media/" + mediaID.String()
+	thumbnailKey := storageKey + "_thumb"
+
+	if err := cfg.Storage.Put(r.Context(), storageKey, img.Data, img.ContentType); err != nil {
+		log.Printf("could not store media %v: %v", mediaID, err)
+		respondWithError(w, r, 500, "could not store file")
+		return
+	}
+
+	if err := cfg.Storage.Put(r.Context(), thumbnailKey, img.Thumbnail, img.ThumbnailType); err != nil {
+		log.Printf("could not store thumbnail of media %v: %v", mediaID, err)
+		cfg.deleteBlobs(context.Background(), storageKey)
+		respondWithError(w, r, 500, "could not store file")
+		return
+	}
+
+	medium, err := cfg.DbQueries.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:           mediaID,
+		UserID:       uuid.NullUUID{UUID: userID, Valid: true},
+		ContentType:  img.ContentType,
+		SizeBytes:    int32(len(img.Data)),
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		AltText:      altText,
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		cfg.deleteBlobs(context.Background(), storageKey, thumbnailKey)
+		respondWithError(w, r, 500, "could not save media")
+		return
+	}
+
+	respondWithJSON(w, 201, convertDatabaseMedia(medium))
+
+}
+
+func (cfg *ApiConfig) MediaUpdate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid media id")
+		return
+	}
+
+	req := mediaUpdateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	if len(req.AltText) > MaxAltTextLength {
+		respondWithError(w, r, 400, fmt.Sprintf("alt text is longer than %d characters", MaxAltTextLength))
+		return
+	}
+
+	medium, err := cfg.DbQueries.GetMediaByID(r.Context(), mediaID)
+	if err != nil || medium.UserID.UUID != userID {
+		respondWithError(w, r, 404, "media not found")
+		return
+	}
+
+	medium, err = cfg.DbQueries.UpdateMediaAltText(r.Context(), database.UpdateMediaAltTextParams{
+		ID:      mediaID,
+		AltText: req.AltText,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not update media")
+		return
+	}
+
+	respondWithJSON(w, 200, convertDatabaseMedia(medium))
+
+}
+
+func (cfg *ApiConfig) MediaGet(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, false)
+}
+
+func (cfg *ApiConfig) MediaGetThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, true)
+}
+
+func (cfg *ApiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	medium, err := cfg.DbQueries.GetMediaByID(r.Context(), mediaID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Attachments follow the visibility of their chirp. Unattached uploads
+	// are only known to the uploader by their random id.
+	cacheControl := "public, max-age=31536000, immutable"
+	if medium.ChirpID.Valid {
+		chirp, err := cfg.DbQueries.GetChirpByID(r.Context(), medium.ChirpID.UUID)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if visible, err := cfg.canViewChirp(r.Context(), userIDFromContext(r.Context()), chirp); err != nil || !visible {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !visibility.CanView(chirp.Visibility, visibility.Relationship{}) {
+			cacheControl = "private, max-age=3600"
+		}
+	}
+
+	key, contentType := medium.StorageKey, medium.ContentType
+	if thumbnail {
+		key = medium.ThumbnailKey
+		if contentType != "image/jpeg" {
+			contentType = "image/png"
+		}
+	}
+
+	blob, err := cfg.Storage.Get(r.Context(), key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("could not read media %v: %v", mediaID, err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", cacheControl)
+
+	if _, err := io.Copy(w, blob); err != nil {
+		log.Printf("could not send media %v: %v", mediaID, err)
+	}
+
+}
+
+// attachMedia links uploaded media to a freshly created chirp. All ids have to
+// belong to the author and must not be attached to another chirp yet.
+func attachMedia(ctx context.Context, queries *database.Queries, chirpID uuid.UUID, userID uuid.UUID, mediaIDs []uuid.UUID) error {
+
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+
+	if len(mediaIDs) > MaxMediaPerChirp {
+		return fmt.Errorf("a chirp can have at most %d attachments", MaxMediaPerChirp)
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, id := range mediaIDs {
+		if seen[id] {
+			return fmt.Errorf("media %v is attached twice", id)
+		}
+		seen[id] = true
+	}
+
+	attached, err := queries.AttachMediaToChirp(ctx, database.AttachMediaToChirpParams{
+		ChirpID:  chirpID,
+		MediaIds: mediaIDs,
+		UserID:   userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(attached) != len(mediaIDs) {
+		return fmt.Errorf("media not found or already attached")
+	}
+
+	return nil
+
+}
+
+// CleanupOrphanedMedia removes uploads that were never attached to a chirp,
+// and media whose chirp or uploader has been deleted.
+func (cfg *ApiConfig) CleanupOrphanedMedia(ctx context.Context) error {
+
+	orphans, err := cfg.DbQueries.GetOrphanedMedia(ctx, time.Now().UTC().Add(-cfg.MediaOrphanTTL))
+	if err != nil {
+		return err
+	}
+
+	for _, medium := range orphans {
+		if err := cfg.deleteBlobs(ctx, medium.StorageKey, medium.ThumbnailKey); err != nil {
+			log.Printf("could not remove files of media %v: %v", medium.ID, err)
+			continue
+		}
+
+		if err := cfg.DbQueries.DeleteMediaByID(ctx, medium.ID); err != nil {
+			return err
+		}
+	}
+
+	if len(orphans) > 0 {
+		log.Printf("removed %d orphaned media", len(orphans))
+	}
+
+	return nil
+
+}
+
+func (cfg *ApiConfig) deleteBlobs(ctx context.Context, keys ...string) error {
+
+	for _, key := range keys {
+		if err := cfg.Storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+func convertDatabaseMedia(medium database.Medium) mediaResponse {
+	return mediaResponse{
+		ID:           medium.ID,
+		URL:          "/media/" + medium.ID.String(),
+		ThumbnailURL: "/media/" + medium.ID.String() + "/thumbnail",
+		ContentType:  medium.ContentType,
+		Width:        medium.Width,
+		Height:       medium.Height,
+		AltText:      medium.AltText,
+	}
+}
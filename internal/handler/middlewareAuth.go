@@ -1,30 +1,38 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/sebasukodo/chirpy/internal/auth"
 	"github.com/sebasukodo/chirpy/internal/database"
 )
 
+type contextKey string
+
+const userIDContextKey contextKey = "userID"
+
+var errAccountDeactivated = errors.New("account is deactivated")
+
 func (cfg *ApiConfig) MiddlewareCheckAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		err := cfg.ValidateAuth(w, r)
+		userID, err := cfg.ValidateAuth(w, r)
 		if err != nil {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDContextKey, userID)))
 	})
 }
 
 func (cfg *ApiConfig) MiddlewareCheckAuthLoginPage(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		err := cfg.ValidateAuth(w, r)
+		_, err := cfg.ValidateAuth(w, r)
 		if err != nil {
 			next.ServeHTTP(w, r)
 			return
@@ -35,25 +43,114 @@ func (cfg *ApiConfig) MiddlewareCheckAuthLoginPage(next http.Handler) http.Handl
 	})
 }
 
-func (cfg *ApiConfig) ValidateAuth(w http.ResponseWriter, r *http.Request) error {
+// MiddlewareAPIAuth accepts either a bearer JWT or the session cookies of the
+// web frontend and answers with 401 instead of redirecting to the login page.
+func (cfg *ApiConfig) MiddlewareAPIAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-	if _, err := cfg.ValidateSessionID(w, r); err == nil {
-		return nil
+		var userID uuid.UUID
+		var err error
+
+		if bearer, bearerErr := auth.GetBearerToken(r.Header); bearerErr == nil {
+			userID, err = cfg.validateBearer(r.Context(), bearer)
+		} else {
+			userID, err = cfg.ValidateAuth(w, r)
+		}
+
+		if err != nil {
+			respondWithError(w, r, 401, "Access Denied")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDContextKey, userID)))
+	})
+}
+
+// MiddlewareOptionalAuth identifies the user like MiddlewareAPIAuth when
+// credentials are sent, but lets anonymous requests through.
+func (cfg *ApiConfig) MiddlewareOptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		userID := uuid.Nil
+
+		if bearer, err := auth.GetBearerToken(r.Header); err == nil {
+			if id, err := cfg.validateBearer(r.Context(), bearer); err == nil {
+				userID = id
+			}
+		} else if hasSessionCookies(r) {
+			if id, err := cfg.ValidateAuth(w, r); err == nil {
+				userID = id
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDContextKey, userID)))
+	})
+}
+
+// validateBearer accepts a JWT only while its account is active. Sessions are
+// revoked on deactivation, but issued JWTs stay valid until they expire.
+func (cfg *ApiConfig) validateBearer(ctx context.Context, bearer string) (uuid.UUID, error) {
+
+	userID, err := auth.ValidateJWT(bearer, cfg.TokenSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := cfg.DbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if user.DeletedAt.Valid {
+		return uuid.Nil, errAccountDeactivated
+	}
+
+	return userID, nil
+
+}
+
+func hasSessionCookies(r *http.Request) bool {
+
+	for _, name := range []string{"session_id", "refresh_token"} {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+func (cfg *ApiConfig) ValidateAuth(w http.ResponseWriter, r *http.Request) (uuid.UUID, error) {
+
+	if session, err := cfg.ValidateSessionID(w, r); err == nil {
+		return session.UserID, nil
 	}
 
 	userID, err := cfg.RotateRefreshToken(w, r)
 	if err != nil {
 		cfg.RemoveAllCookies(w)
-		return err
+		return uuid.Nil, err
 	}
 
-	_, err = cfg.MakeSession(userID, w, r)
+	session, err := cfg.MakeSession(userID, w, r)
 	if err != nil {
 		cfg.RemoveAllCookies(w)
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
+	return session.UserID, nil
+}
+
+func userIDFromContext(ctx context.Context) uuid.UUID {
+
+	userID, ok := ctx.Value(userIDContextKey).(uuid.UUID)
+	if !ok {
+		return uuid.Nil
+	}
+
+	return userID
+
 }
 
 func (cfg *ApiConfig) GetAllCookies(w http.ResponseWriter, r *http.Request) (database.SessionID, database.RefreshToken, bool, error) {
@@ -0,0 +1,406 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	NotificationTypeFollow      = "follow"
+	NotificationTypeMention     = "mention"
+	DefaultNotificationPageSize = 20
+	MaxNotificationPageSize     = 100
+)
+
+// Chirpy has no replies or likes yet. Once they exist, their handlers publish
+// events and the types are added here, preferences and grouping pick them up.
+var notificationTypes = []string{NotificationTypeFollow, NotificationTypeMention}
+
+type notificationResponse struct {
+	ID         uuid.UUID   `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Type       string      `json:"type"`
+	ChirpID    *uuid.UUID  `json:"chirp_id"`
+	ActorIDs   []uuid.UUID `json:"actor_ids"`
+	ActorCount int         `json:"actor_count"`
+	Summary    string      `json:"summary"`
+	Read       bool        `json:"read"`
+}
+
+type notificationPageResponse struct {
+	Notifications []notificationResponse `json:"notifications"`
+	NextCursor    string                 `json:"next_cursor,omitempty"`
+}
+
+type notificationsReadRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+// SubscribeNotifications turns the events users care about into notifications.
+func (cfg *ApiConfig) SubscribeNotifications(bus events.Bus) {
+	bus.Subscribe(events.TypeUserFollowed, cfg.notify(NotificationTypeFollow))
+	bus.Subscribe(events.TypeUserMentioned, cfg.notify(NotificationTypeMention))
+}
+
+// notify stores the event as a notification for the affected user. While it
+// is unread, further events about the same thing add their actor to it.
+func (cfg *ApiConfig) notify(notificationType string) events.Handler {
+	return func(ctx context.Context, event events.Event) {
+
+		if event.Remote || event.ActorID == event.UserID {
+			return
+		}
+
+		params := database.UpsertNotificationParams{
+			UserID:   event.UserID,
+			Type:     notificationType,
+			GroupKey: notificationType,
+		}
+
+		if event.ChirpID != uuid.Nil {
+			params.GroupKey += ":" + event.ChirpID.String()
+			params.ChirpID = uuid.NullUUID{UUID: event.ChirpID, Valid: true}
+		}
+
+		notification, err := cfg.DbQueries.UpsertNotification(ctx, params)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The user turned this type of notification off.
+			return
+		}
+		if err != nil {
+			log.Printf("could not store %s notification for user %v: %v", notificationType, event.UserID, err)
+			return
+		}
+
+		if err := cfg.DbQueries.AddNotificationActor(ctx, database.AddNotificationActorParams{
+			NotificationID: notification.ID,
+			ActorID:        event.ActorID,
+		}); err != nil {
+			log.Printf("could not add actor to notification %v: %v", notification.ID, err)
+		}
+
+	}
+}
+
+// NotificationsList pages backwards through the user's notifications, most
+// recently updated first. The cursor is the id of the last notification.
+func (cfg *ApiConfig) NotificationsList(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	pageSize, err := parsePageSize(r, DefaultNotificationPageSize, MaxNotificationPageSize)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	notifications, err := cfg.notificationsPage(r.Context(), userID, r.URL.Query().Get("before"), pageSize)
+	if errors.Is(err, errInvalidCursor) {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve notifications")
+		return
+	}
+
+	response := notificationPageResponse{Notifications: notifications}
+	if len(notifications) == pageSize {
+		response.NextCursor = notifications[len(notifications)-1].ID.String()
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) NotificationsUnreadCount(w http.ResponseWriter, r *http.Request) {
+
+	unread, err := cfg.DbQueries.GetUnreadNotificationCount(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not count unread notifications")
+		return
+	}
+
+	respondWithJSON(w, 200, map[string]int64{"unread": unread})
+
+}
+
+// NotificationsMarkRead marks the given notifications as read, or all of
+// them when no ids are sent.
+func (cfg *ApiConfig) NotificationsMarkRead(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	req := notificationsReadRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, r, 400, "could not decode json message")
+			return
+		}
+	}
+
+	var err error
+	if len(req.IDs) == 0 {
+		err = cfg.DbQueries.MarkAllNotificationsRead(r.Context(), userID)
+	} else {
+		err = cfg.DbQueries.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			UserID: userID,
+			Ids:    req.IDs,
+		})
+	}
+
+	if err != nil {
+		respondWithError(w, r, 500, "could not mark notifications as read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) NotificationPreferencesGet(w http.ResponseWriter, r *http.Request) {
+
+	preferences, err := cfg.notificationPreferences(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve notification preferences")
+		return
+	}
+
+	respondWithJSON(w, 200, preferences)
+
+}
+
+// NotificationPreferencesUpdate takes a map of notification type to whether
+// it is enabled. Types that are left out keep their setting.
+func (cfg *ApiConfig) NotificationPreferencesUpdate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	req := map[string]bool{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	for notificationType := range req {
+		if !slices.Contains(notificationTypes, notificationType) {
+			respondWithError(w, r, 400, fmt.Sprintf("unknown notification type %q", notificationType))
+			return
+		}
+	}
+
+	for notificationType, enabled := range req {
+		if err := cfg.DbQueries.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		}); err != nil {
+			respondWithError(w, r, 500, "could not update notification preferences")
+			return
+		}
+	}
+
+	preferences, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve notification preferences")
+		return
+	}
+
+	respondWithJSON(w, 200, preferences)
+
+}
+
+func (cfg *ApiConfig) NotificationsPage(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	notifications, err := cfg.notificationsPage(r.Context(), userID, "", DefaultNotificationPageSize)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve notifications")
+		return
+	}
+
+	views := make([]templates.NotificationView, 0, len(notifications))
+	for _, notification := range notifications {
+		view := templates.NotificationView{
+			Summary:   notification.Summary,
+			UpdatedAt: notification.UpdatedAt.Format("January 2, 2006 15:04"),
+			Unread:    !notification.Read,
+		}
+		if notification.ChirpID != nil {
+			view.Link = "/chirps/" + notification.ChirpID.String()
+		}
+		views = append(views, view)
+	}
+
+	// Opening the page counts as seeing everything on it.
+	if err := cfg.DbQueries.MarkAllNotificationsRead(r.Context(), userID); err != nil {
+		respondWithError(w, r, 500, "could not mark notifications as read")
+		return
+	}
+
+	respondWithHTML(templates.Layout(templates.Notifications(views), "Notifications"), w, r)
+
+}
+
+// NotificationBell renders the bell of the navigation bar. It is loaded by
+// htmx so the layout does not need to know who is logged in.
+func (cfg *ApiConfig) NotificationBell(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+	if userID == uuid.Nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	unread, err := cfg.DbQueries.GetUnreadNotificationCount(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not count unread notifications")
+		return
+	}
+
+	respondWithHTML(templates.NotificationBell(int(unread)), w, r)
+
+}
+
+func (cfg *ApiConfig) notificationsPage(ctx context.Context, userID uuid.UUID, before string, pageSize int) ([]notificationResponse, error) {
+
+	params := database.GetNotificationsPageParams{
+		UserID:          userID,
+		BeforeUpdatedAt: pageStart,
+		BeforeID:        uuid.Max,
+		PageSize:        int32(pageSize),
+	}
+
+	if before != "" {
+		cursorID, err := uuid.Parse(before)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+
+		cursor, err := cfg.DbQueries.GetNotificationByID(ctx, cursorID)
+		if err != nil || cursor.UserID != userID {
+			return nil, errInvalidCursor
+		}
+
+		params.BeforeUpdatedAt = cursor.UpdatedAt
+		params.BeforeID = cursor.ID
+	}
+
+	notifications, err := cfg.DbQueries.GetNotificationsPage(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]notificationResponse, 0, len(notifications))
+	if len(notifications) == 0 {
+		return response, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(notifications))
+	for _, notification := range notifications {
+		ids = append(ids, notification.ID)
+	}
+
+	actors, err := cfg.DbQueries.GetNotificationActors(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	actorsByNotification := map[uuid.UUID][]uuid.UUID{}
+	allActorIDs := []uuid.UUID{}
+	for _, actor := range actors {
+		actorsByNotification[actor.NotificationID] = append(actorsByNotification[actor.NotificationID], actor.ActorID)
+		allActorIDs = append(allActorIDs, actor.ActorID)
+	}
+
+	handles, err := cfg.userHandles(ctx, allActorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, notification := range notifications {
+		actorIDs := actorsByNotification[notification.ID]
+		if actorIDs == nil {
+			actorIDs = []uuid.UUID{}
+		}
+
+		item := notificationResponse{
+			ID:         notification.ID,
+			CreatedAt:  notification.CreatedAt,
+			UpdatedAt:  notification.UpdatedAt,
+			Type:       notification.Type,
+			ActorIDs:   actorIDs,
+			ActorCount: len(actorIDs),
+			Summary:    notificationSummary(notification.Type, actorIDs, handles),
+			Read:       notification.ReadAt.Valid,
+		}
+
+		if notification.ChirpID.Valid {
+			item.ChirpID = &notification.ChirpID.UUID
+		}
+
+		response = append(response, item)
+	}
+
+	return response, nil
+
+}
+
+func (cfg *ApiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+
+	stored, err := cfg.DbQueries.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := map[string]bool{}
+	for _, notificationType := range notificationTypes {
+		preferences[notificationType] = true
+	}
+
+	for _, preference := range stored {
+		preferences[preference.Type] = preference.Enabled
+	}
+
+	return preferences, nil
+
+}
+
+// notificationSummary names the most recent actor and counts the rest, as in
+// "@chirper and 4 others followed you". actorIDs are newest first.
+func notificationSummary(notificationType string, actorIDs []uuid.UUID, handles map[uuid.UUID]string) string {
+
+	action := "did something"
+	switch notificationType {
+	case NotificationTypeFollow:
+		action = "followed you"
+	case NotificationTypeMention:
+		action = "mentioned you in a chirp"
+	}
+
+	switch len(actorIDs) {
+	case 0:
+		return "Someone " + action
+	case 1:
+		return fmt.Sprintf("%s %s", userLabel(handles[actorIDs[0]], false), action)
+	case 2:
+		return fmt.Sprintf("%s and %s %s", userLabel(handles[actorIDs[0]], false), userLabel(handles[actorIDs[1]], false), action)
+	}
+
+	return fmt.Sprintf("%s and %d others %s", userLabel(handles[actorIDs[0]], false), len(actorIDs)-1, action)
+
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultChirpPageSize = 20
+	MaxChirpPageSize     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageStart is the cursor time of a first page, newer than anything stored.
+var pageStart = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type chirpPageResponse struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// parsePageSize reads the limit query parameter of paginated endpoints.
+func parsePageSize(r *http.Request, defaultSize int, maxSize int) (int, error) {
+
+	limit := r.URL.Query().Get("limit")
+	if limit == "" {
+		return defaultSize, nil
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 || n > maxSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxSize)
+	}
+
+	return n, nil
+
+}
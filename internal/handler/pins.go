@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+)
+
+// ChirpsPin pins a chirp to the top of its author's profile. The most
+// recently pinned chirp comes first.
+func (cfg *ApiConfig) ChirpsPin(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	chirp, ok := cfg.ownChirpFromPath(w, r, userID)
+	if !ok {
+		return
+	}
+
+	// Pinning again keeps the position and must not count against the limit.
+	if chirp.PinPosition.Valid {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not pin chirp")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	// Locking the user makes concurrent pins wait for each other, so they
+	// cannot both pass the limit.
+	user, err := queries.LockUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	count, err := queries.CountPinnedChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not pin chirp")
+		return
+	}
+
+	if limit := cfg.entitlementsFor(user).MaxPinnedChirps; count >= int64(limit) {
+		respondWithError(w, r, 403, fmt.Sprintf("you can pin at most %d chirps", limit))
+		return
+	}
+
+	if _, err := queries.PinChirp(r.Context(), database.PinChirpParams{
+		ID:     chirp.ID,
+		UserID: userID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not pin chirp")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not pin chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) ChirpsUnpin(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	chirp, ok := cfg.ownChirpFromPath(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		ID:     chirp.ID,
+		UserID: userID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not unpin chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// ownChirpFromPath checks that the chirp in the path belongs to the user and
+// writes the error response if it does not.
+func (cfg *ApiConfig) ownChirpFromPath(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Chirp, bool) {
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid chirp id")
+		return database.Chirp{}, false
+	}
+
+	chirp, err := cfg.DbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 404, "chirp not found")
+		return database.Chirp{}, false
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, r, 403, "Access Denied")
+		return database.Chirp{}, false
+	}
+
+	return chirp, true
+
+}
+
+// pinnedChirps returns the author's pinned chirps that the viewer may see.
+func (cfg *ApiConfig) pinnedChirps(ctx context.Context, viewerID uuid.UUID, authorID uuid.UUID) ([]chirpResponse, error) {
+
+	pinned, err := cfg.DbQueries.GetPinnedChirps(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible, err := cfg.visibleChirps(ctx, viewerID, pinned, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.buildChirpResponses(ctx, viewerID, visible)
+
+}
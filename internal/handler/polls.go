@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	MinPollOptions      = 2
+	MaxPollOptions      = 4
+	MaxPollOptionLength = 50
+	MinPollDuration     = 5 * time.Minute
+	MaxPollDuration     = 7 * 24 * time.Hour
+	DefaultPollDuration = 24 * time.Hour
+)
+
+type pollCreateRequest struct {
+	Options         []string `json:"options"`
+	DurationMinutes int      `json:"duration_minutes"`
+}
+
+type pollVoteRequest struct {
+	OptionID uuid.UUID `json:"option_id"`
+}
+
+// Vote counts are left out until the viewer has voted or the poll is closed,
+// so early results do not sway the vote.
+type pollResponse struct {
+	ID             uuid.UUID            `json:"id"`
+	ExpiresAt      time.Time            `json:"expires_at"`
+	Closed         bool                 `json:"closed"`
+	Voted          bool                 `json:"voted"`
+	OwnVote        *uuid.UUID           `json:"own_vote,omitempty"`
+	ResultsVisible bool                 `json:"results_visible"`
+	TotalVotes     *int32               `json:"total_votes,omitempty"`
+	Options        []pollOptionResponse `json:"options"`
+}
+
+type pollOptionResponse struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int32    `json:"votes,omitempty"`
+}
+
+func (cfg *ApiConfig) ChirpsPollVote(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid chirp id")
+		return
+	}
+
+	req := pollVoteRequest{}
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, r, 400, "could not decode json message")
+			return
+		}
+	} else if req.OptionID, err = uuid.Parse(r.FormValue("option_id")); err != nil {
+		respondWithError(w, r, 400, "option_id is required")
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	if visible, err := cfg.canViewChirp(r.Context(), userID, chirp); err != nil || !visible {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	poll, err := cfg.DbQueries.GetPollByChirpID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 404, "chirp has no poll")
+		return
+	}
+
+	if !time.Now().UTC().Before(poll.ExpiresAt) {
+		respondWithError(w, r, 409, "poll is closed")
+		return
+	}
+
+	_, err = cfg.DbQueries.CastPollVote(r.Context(), database.CastPollVoteParams{
+		UserID:   userID,
+		OptionID: req.OptionID,
+		PollID:   poll.ID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 500, "could not save vote")
+		return
+	}
+
+	polls, loadErr := cfg.loadPolls(r.Context(), userID, []uuid.UUID{chirpID})
+	if loadErr != nil {
+		respondWithError(w, r, 500, "could not retrieve poll")
+		return
+	}
+	current := polls[chirpID]
+
+	// No row means the vote was not recorded, find out why.
+	if errors.Is(err, sql.ErrNoRows) {
+		switch {
+		case current.Voted:
+			respondWithError(w, r, 409, "you already voted in this poll")
+		case current.Closed:
+			respondWithError(w, r, 409, "poll is closed")
+		default:
+			respondWithError(w, r, 400, "option does not belong to this poll")
+		}
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		respondWithHTML(templates.ChirpPoll(convertPollView(chirpID, current)), w, r)
+		return
+	}
+
+	respondWithJSON(w, 201, current)
+
+}
+
+func validatePoll(req *pollCreateRequest) ([]string, time.Duration, error) {
+
+	if len(req.Options) < MinPollOptions || len(req.Options) > MaxPollOptions {
+		return nil, 0, fmt.Errorf("a poll needs %d to %d options", MinPollOptions, MaxPollOptions)
+	}
+
+	options := make([]string, 0, len(req.Options))
+	seen := map[string]bool{}
+
+	for _, option := range req.Options {
+		option = strings.TrimSpace(option)
+
+		if option == "" {
+			return nil, 0, fmt.Errorf("poll options must not be empty")
+		}
+
+		if len(option) > MaxPollOptionLength {
+			return nil, 0, fmt.Errorf("poll options must be at most %d characters", MaxPollOptionLength)
+		}
+
+		if seen[strings.ToLower(option)] {
+			return nil, 0, fmt.Errorf("poll options must be unique")
+		}
+
+		seen[strings.ToLower(option)] = true
+		options = append(options, removeSlurs(option))
+	}
+
+	duration := DefaultPollDuration
+	if req.DurationMinutes != 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+
+	if duration < MinPollDuration || duration > MaxPollDuration {
+		return nil, 0, fmt.Errorf("poll duration must be between %v and %v", MinPollDuration, MaxPollDuration)
+	}
+
+	return options, duration, nil
+
+}
+
+func createPoll(ctx context.Context, queries *database.Queries, chirpID uuid.UUID, options []string, duration time.Duration) error {
+
+	poll, err := queries.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:   chirpID,
+		ExpiresAt: time.Now().UTC().Add(duration),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, option := range options {
+		if err := queries.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   poll.ID,
+			Position: int32(i),
+			Text:     option,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// loadPolls returns the polls of the given chirps as seen by viewerID, which
+// is uuid.Nil for anonymous requests.
+func (cfg *ApiConfig) loadPolls(ctx context.Context, viewerID uuid.UUID, chirpIDs []uuid.UUID) (map[uuid.UUID]*pollResponse, error) {
+
+	polls, err := cfg.DbQueries.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil || len(polls) == 0 {
+		return map[uuid.UUID]*pollResponse{}, err
+	}
+
+	pollIDs := make([]uuid.UUID, 0, len(polls))
+	for _, poll := range polls {
+		pollIDs = append(pollIDs, poll.ID)
+	}
+
+	options, err := cfg.DbQueries.GetPollOptionsForPolls(ctx, pollIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	votes := map[uuid.UUID]uuid.UUID{}
+	if viewerID != uuid.Nil {
+		ownVotes, err := cfg.DbQueries.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+			UserID:  viewerID,
+			PollIds: pollIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range ownVotes {
+			votes[vote.PollID] = vote.OptionID
+		}
+	}
+
+	optionsByPoll := map[uuid.UUID][]database.PollOption{}
+	for _, option := range options {
+		optionsByPoll[option.PollID] = append(optionsByPoll[option.PollID], option)
+	}
+
+	now := time.Now().UTC()
+	result := map[uuid.UUID]*pollResponse{}
+
+	for _, poll := range polls {
+		response := &pollResponse{
+			ID:        poll.ID,
+			ExpiresAt: poll.ExpiresAt,
+			Closed:    !now.Before(poll.ExpiresAt),
+			Options:   []pollOptionResponse{},
+		}
+
+		if optionID, ok := votes[poll.ID]; ok {
+			response.Voted = true
+			response.OwnVote = &optionID
+		}
+
+		response.ResultsVisible = response.Voted || response.Closed
+
+		total := int32(0)
+		for _, option := range optionsByPoll[poll.ID] {
+			optionResponse := pollOptionResponse{ID: option.ID, Text: option.Text}
+			if response.ResultsVisible {
+				optionResponse.Votes = &option.Votes
+				total += option.Votes
+			}
+			response.Options = append(response.Options, optionResponse)
+		}
+
+		if response.ResultsVisible {
+			response.TotalVotes = &total
+		}
+
+		result[poll.ChirpID] = response
+	}
+
+	return result, nil
+
+}
+
+func convertPollView(chirpID uuid.UUID, poll *pollResponse) templates.ChirpPollView {
+
+	view := templates.ChirpPollView{
+		ChirpID:     chirpID.String(),
+		Closed:      poll.Closed,
+		ShowResults: poll.ResultsVisible,
+		ExpiresAt:   poll.ExpiresAt.Format("January 2, 2006 15:04"),
+	}
+
+	if poll.TotalVotes != nil {
+		view.TotalVotes = int(*poll.TotalVotes)
+	}
+
+	for _, option := range poll.Options {
+		optionView := templates.ChirpPollOptionView{
+			ID:     option.ID.String(),
+			Text:   option.Text,
+			Chosen: poll.OwnVote != nil && *poll.OwnVote == option.ID,
+		}
+
+		if option.Votes != nil {
+			optionView.Votes = int(*option.Votes)
+			if view.TotalVotes > 0 {
+				optionView.Percent = optionView.Votes * 100 / view.TotalVotes
+			}
+		}
+
+		view.Options = append(view.Options, optionView)
+	}
+
+	return view
+
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/jobs"
+	"github.com/sebasukodo/chirpy/internal/preview"
+)
+
+const (
+	PreviewFetchTimeout = 10 * time.Second
+	// Previews stuck in "fetching" longer than this are picked up again.
+	PreviewClaimTimeout = 2 * time.Minute
+)
+
+type linkPreviewJob struct {
+	URL string `json:"url"`
+}
+
+type linkPreviewResponse struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// previewURL picks the link of a chirp body that gets a card, the first one.
+func previewURL(body string) sql.NullString {
+
+	urls := preview.ExtractURLs(body)
+	if len(urls) == 0 {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: urls[0], Valid: true}
+
+}
+
+// queueLinkPreview stores the link of a chirp and queues a job to fetch it,
+// within the transaction of the chirp. Results are cached per URL, so a link
+// shared in many chirps is only fetched once.
+func (cfg *ApiConfig) queueLinkPreview(ctx context.Context, tx *sql.Tx, url string) error {
+
+	if err := cfg.DbQueries.WithTx(tx).QueueLinkPreview(ctx, url); err != nil {
+		return err
+	}
+
+	return cfg.Jobs.WithTx(tx).Enqueue(ctx, jobs.Job{
+		Kind:      JobFetchLinkPreview,
+		Payload:   linkPreviewJob{URL: url},
+		UniqueKey: url,
+	})
+
+}
+
+// fetchLinkPreviewJob fetches the metadata of a queued link. A link that is
+// already fetched, or being fetched by another worker, is left alone.
+func (cfg *ApiConfig) fetchLinkPreviewJob(ctx context.Context, payload []byte) error {
+
+	job := linkPreviewJob{}
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	link, err := cfg.DbQueries.ClaimLinkPreview(ctx, database.ClaimLinkPreviewParams{
+		Url:       job.URL,
+		UpdatedAt: time.Now().UTC().Add(-PreviewClaimTimeout),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, PreviewFetchTimeout)
+	card, err := cfg.PreviewFetcher.Fetch(fetchCtx, link.Url)
+	cancel()
+
+	if err != nil {
+		return cfg.DbQueries.SetLinkPreviewFailed(ctx, database.SetLinkPreviewFailedParams{
+			Url:   link.Url,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		})
+	}
+
+	return cfg.DbQueries.SetLinkPreviewReady(ctx, database.SetLinkPreviewReadyParams{
+		Url:         link.Url,
+		Title:       card.Title,
+		Description: card.Description,
+		ImageUrl:    card.ImageURL,
+		SiteName:    card.SiteName,
+	})
+
+}
+
+func convertDatabaseLinkPreview(link database.LinkPreview) *linkPreviewResponse {
+	return &linkPreviewResponse{
+		URL:         link.Url,
+		Title:       link.Title,
+		Description: link.Description,
+		ImageURL:    link.ImageUrl,
+		SiteName:    link.SiteName,
+	}
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/handle"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 160
+)
+
+// profileResponse is what everyone may see about a user. It must never
+// contain the email address.
+type profileResponse struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	Badge       string    `json:"badge,omitempty"`
+}
+
+type profileUpdateRequest struct {
+	Handle        string     `json:"handle"`
+	DisplayName   string     `json:"display_name"`
+	Bio           string     `json:"bio"`
+	AvatarMediaID *uuid.UUID `json:"avatar_media_id"`
+}
+
+func (cfg *ApiConfig) UsersGetProfile(w http.ResponseWriter, r *http.Request) {
+
+	user, err := cfg.profileFromPath(r)
+	if err != nil {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	respondWithJSON(w, 200, cfg.convertProfile(user))
+
+}
+
+// UsersUpdateProfile replaces the public profile of the current user. An
+// empty handle keeps the current one, a missing avatar removes it.
+func (cfg *ApiConfig) UsersUpdateProfile(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	req := profileUpdateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	user, err := cfg.DbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 401, "Access Denied")
+		return
+	}
+
+	params := database.UpdateUserProfileParams{
+		ID:          userID,
+		Handle:      user.Handle,
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Bio:         strings.TrimSpace(req.Bio),
+	}
+
+	fieldErrors := map[string]string{}
+
+	if requested := handle.Normalize(req.Handle); requested != "" && requested != user.Handle {
+		if err := handle.Validate(requested); err != nil {
+			fieldErrors["handle"] = err.Error()
+		} else if taken, err := cfg.handleTaken(r.Context(), requested, userID); err != nil {
+			respondWithError(w, r, 500, "could not update profile")
+			return
+		} else if taken {
+			fieldErrors["handle"] = "handle is already taken"
+		}
+		params.Handle = requested
+	}
+
+	if len(params.DisplayName) > MaxDisplayNameLength {
+		fieldErrors["display_name"] = fmt.Sprintf("display name is longer than %d characters", MaxDisplayNameLength)
+	}
+
+	if len(params.Bio) > MaxBioLength {
+		fieldErrors["bio"] = fmt.Sprintf("bio is longer than %d characters", MaxBioLength)
+	}
+
+	// Avatars are regular uploads that are not attached to a chirp, so they
+	// stay visible however the user's chirps are restricted.
+	if req.AvatarMediaID != nil {
+		medium, err := cfg.DbQueries.GetMediaByID(r.Context(), *req.AvatarMediaID)
+		if err != nil || medium.UserID.UUID != userID || medium.ChirpID.Valid {
+			fieldErrors["avatar_media_id"] = "avatar must be an unattached upload of yours"
+		}
+		params.AvatarMediaID = uuid.NullUUID{UUID: *req.AvatarMediaID, Valid: true}
+	}
+
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
+		return
+	}
+
+	user, err = cfg.DbQueries.UpdateUserProfile(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, 500, "could not update profile")
+		return
+	}
+
+	respondWithJSON(w, 200, convertDatabaseUser(user))
+
+}
+
+func (cfg *ApiConfig) UserProfilePage(w http.ResponseWriter, r *http.Request) {
+
+	user, err := cfg.profileFromPath(r)
+	if err != nil {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	viewerID := userIDFromContext(r.Context())
+
+	chirps, err := cfg.DbQueries.GetChirpsByAuthorPage(r.Context(), database.GetChirpsByAuthorPageParams{
+		UserID:          user.ID,
+		BeforeCreatedAt: pageStart,
+		BeforeID:        uuid.Max,
+		PageSize:        MaxChirpPageSize,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
+
+	page, err := cfg.chirpPage(r.Context(), viewerID, chirps, MaxChirpPageSize, true)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
+
+	pinned, err := cfg.pinnedChirps(r.Context(), viewerID, user.ID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
+
+	profile := cfg.convertProfile(user)
+	view := templates.UserProfileView{
+		Handle:      profile.Handle,
+		DisplayName: profile.DisplayName,
+		Bio:         profile.Bio,
+		AvatarURL:   profile.AvatarURL,
+		Badge:       profile.Badge,
+		JoinedAt:    profile.CreatedAt.Format("January 2006"),
+	}
+
+	respondWithHTML(templates.Layout(templates.UserProfile(view, convertChirpViews(pinned), convertChirpViews(page.Chirps)), "@"+profile.Handle), w, r)
+
+}
+
+// profileFromPath looks up the user named by the handle in the path. Users
+// who are deactivated or blocked in either direction do not exist for the
+// viewer.
+func (cfg *ApiConfig) profileFromPath(r *http.Request) (database.User, error) {
+
+	user, err := cfg.DbQueries.GetUserByHandle(r.Context(), handle.Normalize(r.PathValue("handle")))
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if user.DeletedAt.Valid {
+		return database.User{}, fmt.Errorf("user %v is deactivated", user.ID)
+	}
+
+	if viewerID := userIDFromContext(r.Context()); viewerID != uuid.Nil && viewerID != user.ID {
+		blocked, err := cfg.hasBlockWith(r.Context(), viewerID, []uuid.UUID{user.ID})
+		if err != nil {
+			return database.User{}, err
+		}
+		if blocked {
+			return database.User{}, fmt.Errorf("user %v is blocked", user.ID)
+		}
+	}
+
+	return user, nil
+
+}
+
+func (cfg *ApiConfig) handleTaken(ctx context.Context, h string, userID uuid.UUID) (bool, error) {
+
+	existing, err := cfg.DbQueries.GetUserByHandle(ctx, h)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return existing.ID != userID, nil
+
+}
+
+// userHandles maps user ids to their handles for labels in the UI. Deactivated
+// users are left out so they are labelled as such.
+func (cfg *ApiConfig) userHandles(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+
+	handles := map[uuid.UUID]string{}
+	if len(userIDs) == 0 {
+		return handles, nil
+	}
+
+	users, err := cfg.DbQueries.GetUsersByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if !user.DeletedAt.Valid {
+			handles[user.ID] = user.Handle
+		}
+	}
+
+	return handles, nil
+
+}
+
+func (cfg *ApiConfig) convertProfile(user database.User) profileResponse {
+	return profileResponse{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   avatarURL(user),
+		Badge:       cfg.entitlementsFor(user).Badge,
+	}
+}
+
+func avatarURL(user database.User) string {
+	if !user.AvatarMediaID.Valid {
+		return ""
+	}
+	return "/media/" + user.AvatarMediaID.UUID.String() + "/thumbnail"
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/sebasukodo/chirpy/internal/auth"
 	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
 )
 
 const RefreshTokenExpiresInHours = time.Duration(14*24) * time.Hour
@@ -56,6 +57,10 @@ func (cfg *ApiConfig) ValidateRefreshToken(w http.ResponseWriter, r *http.Reques
 	if refreshToken.RevokedAt.Valid {
 		cfg.DbQueries.RevokeAllRefreshTokensForUser(r.Context(), refreshToken.UserID)
 		cfg.DbQueries.RevokeAllSessionsForUser(r.Context(), refreshToken.UserID)
+		cfg.Events.Publish(r.Context(), events.Event{
+			Type:   events.TypeSessionsRevoked,
+			UserID: refreshToken.UserID,
+		})
 		return database.RefreshToken{}, fmt.Errorf("token reuse detected")
 	}
 
@@ -5,18 +5,26 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/a-h/templ"
 	"github.com/sebasukodo/chirpy/templates"
 )
 
+// respondWithError answers the web frontend with an HTML error fragment and
+// everyone else with a JSON error.
 func respondWithError(w http.ResponseWriter, r *http.Request, code int, msg string) {
 
-	w.WriteHeader(code)
+	log.Printf("Responding with error %d: %s\n", code, msg)
+
+	if !wantsHTML(r) {
+		respondWithJSON(w, code, errorResponse{Error: msg})
+		return
+	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	log.Printf("Responding with error %d: %s\n", code, msg)
+	w.WriteHeader(code)
 
 	if err := templates.HTMLError(msg).Render(r.Context(), w); err != nil {
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
@@ -24,6 +32,10 @@ func respondWithError(w http.ResponseWriter, r *http.Request, code int, msg stri
 
 }
 
+func wantsHTML(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true" || strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
 func respondWithHTML(c templ.Component, w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -52,6 +64,22 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 
 }
 
+type errorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+func respondWithFieldErrors(w http.ResponseWriter, fields map[string]string) {
+	respondWithJSON(w, 400, errorResponse{
+		Error:  "validation failed",
+		Fields: fields,
+	})
+}
+
+func isJSONRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
 func Readiness(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
+	"github.com/sebasukodo/chirpy/internal/stream"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+const (
+	StreamBufferSize        = 64
+	StreamHeartbeatInterval = 25 * time.Second
+	StreamRetry             = 3 * time.Second
+	StreamResumeLimit       = 200
+	// StreamSentLimit is how many sent chirps a stream remembers for delete
+	// events. Older chirps have scrolled far out of view anyway.
+	StreamSentLimit = 1000
+)
+
+// chirpStream selects the chirps of one stream. The zero value is the
+// public stream.
+type chirpStream struct {
+	feed     bool
+	authorID uuid.UUID
+}
+
+// SubscribeStreams feeds chirp events into the hub the open streams listen on.
+func (cfg *ApiConfig) SubscribeStreams(bus events.Bus) {
+	publish := func(ctx context.Context, event events.Event) {
+		cfg.ChirpHub.Publish(event)
+	}
+
+	bus.Subscribe(events.TypeChirpCreated, publish)
+	bus.Subscribe(events.TypeChirpDeleted, publish)
+	bus.Subscribe(events.TypeSessionsRevoked, publish)
+}
+
+func (cfg *ApiConfig) StreamPublic(w http.ResponseWriter, r *http.Request) {
+	cfg.streamChirps(w, r, chirpStream{})
+}
+
+func (cfg *ApiConfig) StreamFeed(w http.ResponseWriter, r *http.Request) {
+	cfg.streamChirps(w, r, chirpStream{feed: true})
+}
+
+func (cfg *ApiConfig) StreamUser(w http.ResponseWriter, r *http.Request) {
+
+	authorID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid user id")
+		return
+	}
+
+	author, err := cfg.DbQueries.GetUserByID(r.Context(), authorID)
+	if err != nil || author.DeletedAt.Valid {
+		respondWithError(w, r, 404, "user not found")
+		return
+	}
+
+	cfg.streamChirps(w, r, chirpStream{authorID: authorID})
+
+}
+
+// streamChirps sends new chirps as "chirp" events with the chirp id as event
+// id, and deletions of chirps it sent as "delete" events. Clients that
+// reconnect with a Last-Event-ID first get the chirps they missed. Pages that
+// render chirps before connecting pass the newest one as ?last_event_id=. With
+// ?format=html the data is the rendered chirp card instead of JSON.
+func (cfg *ApiConfig) streamChirps(w http.ResponseWriter, r *http.Request, selected chirpStream) {
+
+	ctx := r.Context()
+	viewerID := userIDFromContext(ctx)
+	asHTML := r.URL.Query().Get("format") == "html"
+
+	// Subscribe before loading missed chirps so none fall in between.
+	sub := cfg.ChirpHub.Subscribe()
+	defer sub.Close()
+
+	sse, err := stream.NewWriter(w, StreamRetry)
+	if err != nil {
+		respondWithError(w, r, 500, err.Error())
+		return
+	}
+
+	sent := stream.NewRecent(StreamSentLimit)
+
+	// The header of a reconnect is newer than the id the page started with.
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	if lastEventID != "" {
+		missed, err := cfg.missedChirps(ctx, lastEventID)
+		if err != nil {
+			log.Printf("could not resume stream after %q: %v", lastEventID, err)
+		}
+
+		missed, err = cfg.streamFilter(ctx, selected, viewerID, missed)
+		if err != nil {
+			return
+		}
+
+		if err := cfg.sendChirps(ctx, sse, viewerID, missed, asHTML, sent); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sub.Dropped():
+			// The client reconnects and catches up with its Last-Event-ID.
+			return
+
+		case <-heartbeat.C:
+			if err := sse.Comment("heartbeat"); err != nil {
+				return
+			}
+
+		case event := <-sub.Events():
+			switch event.Type {
+			case events.TypeSessionsRevoked:
+				// Closing makes the client reconnect, which checks its
+				// credentials again.
+				if viewerID != uuid.Nil && event.UserID == viewerID {
+					return
+				}
+
+			case events.TypeChirpCreated:
+				if sent.Contains(event.ChirpID) {
+					continue
+				}
+
+				// The actor is the author, so most events are ruled out
+				// before loading the chirp.
+				included, err := cfg.streamIncludes(ctx, selected, viewerID, event.ActorID)
+				if err != nil || !included {
+					continue
+				}
+
+				chirp, err := cfg.DbQueries.GetChirpByID(ctx, event.ChirpID)
+				if err != nil {
+					continue
+				}
+
+				if err := cfg.sendChirps(ctx, sse, viewerID, []database.Chirp{chirp}, asHTML, sent); err != nil {
+					return
+				}
+
+			case events.TypeChirpDeleted:
+				// Only chirps this stream delivered, the event says nothing
+				// about who was allowed to see the chirp.
+				if !sent.Contains(event.ChirpID) {
+					continue
+				}
+				sent.Remove(event.ChirpID)
+
+				data := []byte(event.ChirpID.String())
+				if asHTML {
+					data = fmt.Appendf(nil, `<article id="chirp-%s" hx-swap-oob="delete"></article>`, event.ChirpID)
+				}
+
+				if err := sse.Event("", "delete", data); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+}
+
+func (cfg *ApiConfig) missedChirps(ctx context.Context, lastEventID string) ([]database.Chirp, error) {
+
+	lastID, err := uuid.Parse(lastEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := cfg.DbQueries.GetChirpByID(ctx, lastID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.DbQueries.GetChirpsAfter(ctx, database.GetChirpsAfterParams{
+		CreatedAt: last.CreatedAt,
+		ID:        last.ID,
+		PageSize:  StreamResumeLimit,
+	})
+
+}
+
+// sendChirps sends the chirps of the stream the viewer may see and records
+// them in sent.
+func (cfg *ApiConfig) sendChirps(ctx context.Context, sse *stream.Writer, viewerID uuid.UUID, chirps []database.Chirp, asHTML bool, sent *stream.Recent) error {
+
+	included, err := cfg.visibleChirps(ctx, viewerID, chirps, true)
+	if err != nil {
+		return err
+	}
+
+	if len(included) == 0 {
+		return nil
+	}
+
+	responses, err := cfg.buildChirpResponses(ctx, viewerID, included)
+	if err != nil {
+		return err
+	}
+
+	for _, response := range responses {
+		var data []byte
+		if asHTML {
+			buf := bytes.Buffer{}
+			if err := templates.ChirpCard(convertChirpView(response)).Render(ctx, &buf); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+		} else {
+			data, err = json.Marshal(response)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := sse.Event(response.ID.String(), "chirp", data); err != nil {
+			return err
+		}
+		sent.Add(response.ID)
+	}
+
+	return nil
+
+}
+
+// streamFilter keeps the chirps that belong on the stream, looking up the
+// followed authors of a feed at once.
+func (cfg *ApiConfig) streamFilter(ctx context.Context, selected chirpStream, viewerID uuid.UUID, chirps []database.Chirp) ([]database.Chirp, error) {
+
+	followed := map[uuid.UUID]bool{viewerID: true}
+	if selected.feed && len(chirps) > 0 {
+		authorIDs := make([]uuid.UUID, 0, len(chirps))
+		for _, chirp := range chirps {
+			authorIDs = append(authorIDs, chirp.UserID)
+		}
+
+		followees, err := cfg.DbQueries.GetFollowedAmong(ctx, database.GetFollowedAmongParams{
+			FollowerID:  viewerID,
+			FolloweeIds: authorIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, followee := range followees {
+			followed[followee] = true
+		}
+	}
+
+	included := []database.Chirp{}
+	for _, chirp := range chirps {
+		switch {
+		case selected.authorID != uuid.Nil:
+			if chirp.UserID != selected.authorID {
+				continue
+			}
+		case selected.feed:
+			if !followed[chirp.UserID] {
+				continue
+			}
+		}
+		included = append(included, chirp)
+	}
+
+	return included, nil
+
+}
+
+// streamIncludes reports whether chirps by authorID belong on the stream.
+// Visibility is checked separately.
+func (cfg *ApiConfig) streamIncludes(ctx context.Context, selected chirpStream, viewerID uuid.UUID, authorID uuid.UUID) (bool, error) {
+
+	switch {
+	case selected.authorID != uuid.Nil:
+		return authorID == selected.authorID, nil
+
+	case selected.feed:
+		if authorID == viewerID {
+			return true, nil
+		}
+
+		followed, err := cfg.DbQueries.GetFollowedAmong(ctx, database.GetFollowedAmongParams{
+			FollowerID:  viewerID,
+			FolloweeIds: []uuid.UUID{authorID},
+		})
+		if err != nil {
+			return false, err
+		}
+
+		return len(followed) > 0, nil
+	}
+
+	return true, nil
+
+}
+
+// TimelinePage shows the newest public chirps and inserts new ones as they
+// are posted.
+func (cfg *ApiConfig) TimelinePage(w http.ResponseWriter, r *http.Request) {
+
+	viewerID := userIDFromContext(r.Context())
+
+	chirps, err := cfg.DbQueries.GetAllChirps(r.Context())
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
+
+	chirps, err = cfg.visibleChirps(r.Context(), viewerID, chirps, true)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
+
+	// GetAllChirps is oldest first, the timeline shows the newest 50 on top.
+	latest := []database.Chirp{}
+	for i := len(chirps) - 1; i >= 0 && len(latest) < 50; i-- {
+		latest = append(latest, chirps[i])
+	}
+
+	responses, err := cfg.buildChirpResponses(r.Context(), viewerID, latest)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirps")
+		return
+	}
+
+	// The stream starts after the newest chirp on the page, so chirps posted
+	// before it connects are not lost.
+	streamURL := "/api/stream/public?format=html"
+	if len(responses) > 0 {
+		streamURL += "&last_event_id=" + responses[0].ID.String()
+	}
+
+	respondWithHTML(templates.Layout(templates.Timeline(convertChirpViews(responses), streamURL), "Timeline"), w, r)
+
+}
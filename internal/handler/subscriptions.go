@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sebasukodo/chirpy/internal/billing"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/entitlements"
+	"github.com/sebasukodo/chirpy/templates"
+)
+
+// A canceled subscription keeps its perks until the end of the paid period.
+const (
+	SubscriptionStatusActive   = billing.StatusActive
+	SubscriptionStatusCanceled = billing.StatusCanceled
+	SubscriptionStatusExpired  = billing.StatusExpired
+	SubscriptionStatusRefunded = billing.StatusRefunded
+
+	SubscriptionExpiryInterval = 5 * time.Minute
+)
+
+var errUserNotFound = errors.New("user not found")
+
+// applySubscriptionChange records the change and recomputes is_chirpy_red
+// from all subscriptions of the user. Changes to unknown subscriptions other
+// than new ones are ignored, so replayed events are harmless.
+func (cfg *ApiConfig) applySubscriptionChange(ctx context.Context, provider string, change billing.Event) error {
+
+	if _, err := cfg.DbQueries.GetUserByID(ctx, change.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errUserNotFound
+		}
+		return err
+	}
+
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+	now := time.Now().UTC()
+
+	var subscription database.Subscription
+	if change.Status == SubscriptionStatusActive {
+		subscription, err = queries.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:             change.UserID,
+			Plan:               entitlements.PlanRed,
+			Provider:           provider,
+			ProviderRef:        change.SubscriptionRef,
+			Status:             SubscriptionStatusActive,
+			CurrentPeriodStart: now,
+			CurrentPeriodEnd:   toNullTime(change.PeriodEnd),
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		existing, err := queries.GetSubscriptionByProviderRef(ctx, database.GetSubscriptionByProviderRefParams{
+			Provider:    provider,
+			ProviderRef: change.SubscriptionRef,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		params := database.UpdateSubscriptionStatusParams{
+			ID:               existing.ID,
+			Status:           change.Status,
+			CurrentPeriodEnd: sql.NullTime{Time: now, Valid: true},
+			CanceledAt:       existing.CanceledAt,
+		}
+
+		if change.Status == SubscriptionStatusCanceled {
+			params.CanceledAt = sql.NullTime{Time: now, Valid: true}
+			if change.PeriodEnd != nil {
+				params.CurrentPeriodEnd = toNullTime(change.PeriodEnd)
+			} else if existing.CurrentPeriodEnd.Valid {
+				params.CurrentPeriodEnd = existing.CurrentPeriodEnd
+			}
+		}
+
+		subscription, err = queries.UpdateSubscriptionStatus(ctx, params)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := queries.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		SubscriptionID: subscription.ID,
+		Event:          change.Type,
+		Status:         subscription.Status,
+	}); err != nil {
+		return err
+	}
+
+	if err := queries.SyncUserChirpyRed(ctx, change.UserID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+
+}
+
+// ExpireSubscriptions ends subscriptions whose paid period is over.
+func (cfg *ApiConfig) ExpireSubscriptions(ctx context.Context) error {
+
+	expired, err := cfg.DbQueries.ExpireSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range expired {
+		if err := cfg.DbQueries.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+			SubscriptionID: subscription.ID,
+			Event:          "expired",
+			Status:         subscription.Status,
+		}); err != nil {
+			return err
+		}
+
+		if err := cfg.DbQueries.SyncUserChirpyRed(ctx, subscription.UserID); err != nil {
+			return err
+		}
+	}
+
+	if len(expired) > 0 {
+		log.Printf("expired %d subscriptions", len(expired))
+	}
+
+	return nil
+
+}
+
+func convertSubscriptionView(subscription database.Subscription) *templates.SubscriptionView {
+
+	view := &templates.SubscriptionView{
+		Plan:   "Chirpy Red",
+		Status: subscription.Status,
+		Active: subscription.Status == SubscriptionStatusActive || subscription.Status == SubscriptionStatusCanceled,
+	}
+
+	if subscription.CurrentPeriodEnd.Valid {
+		view.PeriodEnd = subscription.CurrentPeriodEnd.Time.Format("January 2, 2006")
+	}
+
+	switch {
+	case view.PeriodEnd == "":
+	case subscription.Status == SubscriptionStatusActive:
+		view.Summary = fmt.Sprintf("Renews on %s", view.PeriodEnd)
+	case subscription.Status == SubscriptionStatusCanceled:
+		view.Summary = fmt.Sprintf("Canceled, perks end on %s", view.PeriodEnd)
+	default:
+		view.Summary = fmt.Sprintf("Ended on %s", view.PeriodEnd)
+	}
+
+	return view
+
+}
+
+func toNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
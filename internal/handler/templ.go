@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/sebasukodo/chirpy/templates"
 )
 
@@ -25,8 +28,51 @@ func (cfg *ApiConfig) Register(w http.ResponseWriter, r *http.Request) {
 
 func (cfg *ApiConfig) ProfilePage(w http.ResponseWriter, r *http.Request) {
 
-	if err := templates.ProfilePage().Render(r.Context(), w); err != nil {
+	exports, err := cfg.DbQueries.GetDataExportsForUser(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
 		respondWithError(w, r, 500, "Error")
 		return
 	}
+
+	var subscription *templates.SubscriptionView
+	current, err := cfg.DbQueries.GetCurrentSubscriptionForUser(r.Context(), userIDFromContext(r.Context()))
+	if err == nil {
+		subscription = convertSubscriptionView(current)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 500, "Error")
+		return
+	}
+
+	if err := templates.ProfilePage(convertExportViews(exports), subscription).Render(r.Context(), w); err != nil {
+		respondWithError(w, r, 500, "Error")
+		return
+	}
+}
+
+func (cfg *ApiConfig) ChirpPage(w http.ResponseWriter, r *http.Request) {
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	if visible, err := cfg.canViewChirp(r.Context(), userIDFromContext(r.Context()), chirp); err != nil || !visible {
+		respondWithError(w, r, 404, "chirp not found")
+		return
+	}
+
+	response, err := cfg.buildChirpResponse(r.Context(), userIDFromContext(r.Context()), chirp)
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve chirp")
+		return
+	}
+
+	respondWithHTML(templates.Layout(templates.ChirpCard(convertChirpView(response)), "Chirp"), w, r)
 }
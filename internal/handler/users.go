@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"net/mail"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sebasukodo/chirpy/internal/auth"
 	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
+	"github.com/sebasukodo/chirpy/internal/handle"
 	"github.com/sebasukodo/chirpy/templates"
 )
 
@@ -16,6 +21,10 @@ type User struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
 	SessionID   string    `json:"session_id"`
 }
@@ -23,18 +32,64 @@ type User struct {
 type userAuth struct {
 	Password   string `json:"password"`
 	Email      string `json:"email"`
+	Handle     string `json:"handle"`
 	RememberMe string `json:"remember_me"`
+	Restore    string `json:"restore"`
 }
 
 func (cfg *ApiConfig) UsersRegisterForm(w http.ResponseWriter, r *http.Request) {
 
+	isAPI := isJSONRequest(r)
+
 	userInfo := userAuth{
 		Password: r.FormValue("password"),
 		Email:    r.FormValue("email"),
+		Handle:   r.FormValue("handle"),
+	}
+
+	if isAPI {
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&userInfo); err != nil {
+			respondWithError(w, r, 400, "could not decode json message")
+			return
+		}
+	}
+
+	userInfo.Handle = handle.Normalize(userInfo.Handle)
+
+	fieldErrors := cfg.validateRegistration(userInfo)
+	if userInfo.Handle == "" {
+		userInfo.Handle = handle.Generate()
+	} else if _, ok := fieldErrors["handle"]; !ok {
+		taken, err := cfg.handleTaken(r.Context(), userInfo.Handle, uuid.Nil)
+		if err != nil {
+			if isAPI {
+				respondWithError(w, r, 500, "could not register user")
+				return
+			}
+			respondWithHTML(templates.RegisterError(), w, r)
+			return
+		}
+		if taken {
+			fieldErrors["handle"] = "Handle is already taken"
+		}
 	}
 
-	hashed, err := auth.HashPassword(userInfo.Password)
+	if len(fieldErrors) > 0 {
+		if isAPI {
+			respondWithFieldErrors(w, fieldErrors)
+			return
+		}
+		respondWithHTML(templates.RegisterFieldErrors(fieldErrors), w, r)
+		return
+	}
+
+	hashed, err := auth.HashPassword(userInfo.Password, cfg.HashParams)
 	if err != nil {
+		if isAPI {
+			respondWithError(w, r, 500, "could not register user")
+			return
+		}
 		respondWithHTML(templates.RegisterError(), w, r)
 		return
 	}
@@ -42,14 +97,26 @@ func (cfg *ApiConfig) UsersRegisterForm(w http.ResponseWriter, r *http.Request)
 	userInfoParams := database.CreateUserParams{
 		Email:          userInfo.Email,
 		HashedPassword: hashed,
+		Handle:         userInfo.Handle,
 	}
 
 	user, err := cfg.DbQueries.CreateUser(r.Context(), userInfoParams)
 	if err != nil {
+		if isAPI {
+			respondWithFieldErrors(w, map[string]string{"email": "User already exists"})
+			return
+		}
 		respondWithHTML(templates.RegisterError(), w, r)
 		return
 	}
 
+	cfg.recordAccountEvent(r.Context(), user.ID, AccountEventRegistered, "")
+
+	if isAPI {
+		respondWithJSON(w, 201, convertDatabaseUser(user))
+		return
+	}
+
 	_, err = cfg.MakeSession(user.ID, w, r)
 	if err != nil {
 		respondWithHTML(templates.RegisterErrorSession(), w, r)
@@ -69,6 +136,7 @@ func (cfg *ApiConfig) UsersLoginForm(w http.ResponseWriter, r *http.Request) {
 		Password:   r.FormValue("password"),
 		Email:      r.FormValue("email"),
 		RememberMe: r.FormValue("remember"),
+		Restore:    r.FormValue("restore"),
 	}
 
 	userInfo, err := cfg.DbQueries.GetUserByEmail(r.Context(), userLoginRequest.Email)
@@ -83,6 +151,31 @@ func (cfg *ApiConfig) UsersLoginForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if userInfo.DeletedAt.Valid {
+		purgeAt := userInfo.DeletedAt.Time.Add(cfg.AccountGracePeriod)
+
+		if time.Now().UTC().After(purgeAt) {
+			respondWithHTML(templates.LoginError(), w, r)
+			return
+		}
+
+		if userLoginRequest.Restore != "1" {
+			respondWithHTML(templates.LoginRestore(purgeAt.Format("January 2, 2006")), w, r)
+			return
+		}
+
+		if err := cfg.DbQueries.RestoreUser(r.Context(), userInfo.ID); err != nil {
+			respondWithHTML(templates.LoginError(), w, r)
+			return
+		}
+
+		cfg.recordAccountEvent(r.Context(), userInfo.ID, AccountEventRestored, "")
+	}
+
+	if auth.NeedsRehash(userInfo.HashedPassword, cfg.HashParams) {
+		cfg.rehashPassword(r.Context(), userInfo.ID, userLoginRequest.Password)
+	}
+
 	_, err = cfg.MakeSession(userInfo.ID, w, r)
 	if err != nil {
 		respondWithHTML(templates.LoginError(), w, r)
@@ -123,12 +216,19 @@ func (cfg *ApiConfig) UsersChangeCredentials(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	userID, err := auth.ValidateJWT(bearer, cfg.TokenSecret)
+	userID, err := cfg.validateBearer(r.Context(), bearer)
 	if err != nil {
 		respondWithError(w, r, 401, "Access Denied")
 		return
 	}
 
+	if userRequest.Password != "" {
+		if err := cfg.PasswordPolicy.Validate(userRequest.Password, userRequest.Email); err != nil {
+			respondWithFieldErrors(w, map[string]string{"password": err.Error()})
+			return
+		}
+	}
+
 	if userRequest.Email != "" {
 		if err := cfg.DbQueries.UpdateUserEmail(r.Context(), database.UpdateUserEmailParams{
 			ID:    userID,
@@ -137,10 +237,12 @@ func (cfg *ApiConfig) UsersChangeCredentials(w http.ResponseWriter, r *http.Requ
 			respondWithError(w, r, 500, "incorrect email or password")
 			return
 		}
+
+		cfg.recordAccountEvent(r.Context(), userID, AccountEventEmailChanged, "")
 	}
 
 	if userRequest.Password != "" {
-		hashedPw, err := auth.HashPassword(userRequest.Password)
+		hashedPw, err := auth.HashPassword(userRequest.Password, cfg.HashParams)
 		if err != nil {
 			respondWithError(w, r, 500, "incorrect email or password")
 			return
@@ -153,6 +255,8 @@ func (cfg *ApiConfig) UsersChangeCredentials(w http.ResponseWriter, r *http.Requ
 			respondWithError(w, r, 500, "incorrect email or password")
 			return
 		}
+
+		cfg.recordAccountEvent(r.Context(), userID, AccountEventPasswordChanged, "")
 	}
 
 	userInfo, err := cfg.DbQueries.GetUserByID(r.Context(), userID)
@@ -189,23 +293,112 @@ func (cfg *ApiConfig) UsersDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := cfg.DbQueries.DeleteUserByID(r.Context(), userID.UserID); err != nil {
+	// Deactivating without revoking would leave the refresh tokens usable.
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "Deletion failed")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	if err := queries.DeactivateUser(r.Context(), userID.UserID); err != nil {
+		respondWithError(w, r, 500, "Deletion failed")
+		return
+	}
+
+	if err := queries.RevokeAllSessionsForUser(r.Context(), userID.UserID); err != nil {
+		respondWithError(w, r, 500, "Deletion failed")
+		return
+	}
+
+	if err := queries.RevokeAllRefreshTokensForUser(r.Context(), userID.UserID); err != nil {
+		respondWithError(w, r, 500, "Deletion failed")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
 		respondWithError(w, r, 500, "Deletion failed")
 		return
 	}
 
+	cfg.Events.Publish(r.Context(), events.Event{
+		Type:    events.TypeSessionsRevoked,
+		ActorID: userID.UserID,
+		UserID:  userID.UserID,
+	})
+
+	cfg.recordAccountEvent(r.Context(), userID.UserID, AccountEventDeactivated, "")
+
 	cfg.RemoveAllCookies(w)
 
 	w.Header().Set("HX-Redirect", "/login")
 	w.WriteHeader(http.StatusSeeOther)
 }
 
+func (cfg *ApiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+
+	hashed, err := auth.HashPassword(password, cfg.HashParams)
+	if err != nil {
+		log.Printf("could not rehash password for user %v: %v", userID, err)
+		return
+	}
+
+	if err := cfg.DbQueries.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashed,
+	}); err != nil {
+		log.Printf("could not store rehashed password for user %v: %v", userID, err)
+		return
+	}
+
+	cfg.recordAccountEvent(ctx, userID, AccountEventPasswordRehashed, "")
+
+}
+
+func (cfg *ApiConfig) validateRegistration(userInfo userAuth) map[string]string {
+
+	fieldErrors := map[string]string{}
+
+	if userInfo.Email == "" {
+		fieldErrors["email"] = "E-Mail is required"
+	} else if _, err := mail.ParseAddress(userInfo.Email); err != nil {
+		fieldErrors["email"] = "E-Mail is not valid"
+	}
+
+	if err := cfg.PasswordPolicy.Validate(userInfo.Password, userInfo.Email); err != nil {
+		fieldErrors["password"] = err.Error()
+	}
+
+	if userInfo.Handle != "" {
+		if err := handle.Validate(userInfo.Handle); err != nil {
+			fieldErrors["handle"] = err.Error()
+		}
+	}
+
+	return fieldErrors
+
+}
+
 func convertDatabaseUser(dbUser database.User) User {
 	return User{
 		ID:          dbUser.ID,
 		CreatedAt:   dbUser.CreatedAt,
 		UpdatedAt:   dbUser.UpdatedAt,
 		Email:       dbUser.Email,
+		Handle:      dbUser.Handle,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		AvatarURL:   avatarURL(dbUser),
 		IsChirpyRed: dbUser.IsChirpyRed,
 	}
 }
+
+// userLabel is how other users are shown in the UI.
+func userLabel(userHandle string, deactivated bool) string {
+	if deactivated || userHandle == "" {
+		return "deactivated account"
+	}
+	return "@" + userHandle
+}
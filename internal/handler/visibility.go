@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/visibility"
+)
+
+// visibleChirps drops the chirps viewerID may not see, viewerID is uuid.Nil
+// for anonymous requests. With listing set, chirps that are only reachable by
+// link and muted chirps are dropped as well. The facts for the policy are
+// loaded in batches.
+func (cfg *ApiConfig) visibleChirps(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp, listing bool) ([]database.Chirp, error) {
+
+	follows := map[uuid.UUID]bool{}
+	mentions := map[uuid.UUID]bool{}
+	blocked := map[uuid.UUID]bool{}
+	muted := map[uuid.UUID]bool{}
+	keywords := []string{}
+
+	if viewerID != uuid.Nil {
+		authorIDs := []uuid.UUID{}
+		otherIDs := []uuid.UUID{}
+		restrictedIDs := []uuid.UUID{}
+
+		for _, chirp := range chirps {
+			if chirp.UserID == viewerID {
+				continue
+			}
+			otherIDs = append(otherIDs, chirp.UserID)
+			switch chirp.Visibility {
+			case visibility.Followers:
+				authorIDs = append(authorIDs, chirp.UserID)
+				restrictedIDs = append(restrictedIDs, chirp.ID)
+			case visibility.Direct:
+				restrictedIDs = append(restrictedIDs, chirp.ID)
+			}
+		}
+
+		if len(authorIDs) > 0 {
+			followed, err := cfg.DbQueries.GetFollowedAmong(ctx, database.GetFollowedAmongParams{
+				FollowerID:  viewerID,
+				FolloweeIds: authorIDs,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range followed {
+				follows[id] = true
+			}
+		}
+
+		if len(otherIDs) > 0 {
+			otherIDs = uniqueIDs(otherIDs)
+
+			blockedIDs, err := cfg.DbQueries.GetBlockedAmong(ctx, database.GetBlockedAmongParams{
+				UserID:   viewerID,
+				OtherIds: otherIDs,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range blockedIDs {
+				blocked[id] = true
+			}
+
+			if listing {
+				mutedIDs, err := cfg.DbQueries.GetMutedAmong(ctx, database.GetMutedAmongParams{
+					MuterID:  viewerID,
+					MutedIds: otherIDs,
+				})
+				if err != nil {
+					return nil, err
+				}
+				for _, id := range mutedIDs {
+					muted[id] = true
+				}
+
+				keywordMutes, err := cfg.DbQueries.GetKeywordMutesForUser(ctx, viewerID)
+				if err != nil {
+					return nil, err
+				}
+				for _, mute := range keywordMutes {
+					keywords = append(keywords, mute.Keyword)
+				}
+			}
+		}
+
+		if len(restrictedIDs) > 0 {
+			mentioned, err := cfg.DbQueries.GetMentionedChirpsAmong(ctx, database.GetMentionedChirpsAmongParams{
+				UserID:   viewerID,
+				ChirpIds: restrictedIDs,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range mentioned {
+				mentions[id] = true
+			}
+		}
+	}
+
+	visible := make([]database.Chirp, 0, len(chirps))
+
+	for _, chirp := range chirps {
+		rel := visibility.Relationship{
+			IsAuthor:  viewerID != uuid.Nil && chirp.UserID == viewerID,
+			Follows:   follows[chirp.UserID],
+			Mentioned: mentions[chirp.ID],
+			Blocked:   blocked[chirp.UserID],
+			Muted:     muted[chirp.UserID] || visibility.ContainsKeyword(chirp.Body, keywords),
+		}
+
+		allowed := visibility.CanView(chirp.Visibility, rel)
+		if listing {
+			allowed = visibility.CanList(chirp.Visibility, rel)
+		}
+
+		if allowed {
+			visible = append(visible, chirp)
+		}
+	}
+
+	return visible, nil
+
+}
+
+func (cfg *ApiConfig) canViewChirp(ctx context.Context, viewerID uuid.UUID, chirp database.Chirp) (bool, error) {
+
+	visible, err := cfg.visibleChirps(ctx, viewerID, []database.Chirp{chirp}, false)
+	if err != nil {
+		return false, err
+	}
+
+	return len(visible) == 1, nil
+
+}
@@ -0,0 +1,648 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/auth"
+	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/events"
+	"github.com/sebasukodo/chirpy/internal/jobs"
+	"github.com/sebasukodo/chirpy/internal/netguard"
+	"github.com/sebasukodo/chirpy/internal/webhook"
+)
+
+const (
+	MaxWebhookEndpoints         = 10
+	WebhookMaxAttempts          = 8
+	WebhookDisableAfterFailures = 10
+	WebhookTimeout              = 10 * time.Second
+	WebhookBackoffBase          = 30 * time.Second
+	WebhookBackoffMax           = 6 * time.Hour
+
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+
+	WebhookEventTest = "webhook.test"
+
+	ChirpySignatureHeader = "Chirpy-Signature"
+)
+
+// WebhookEventTypes can be subscribed to. Each is delivered to the endpoints
+// of the user it is about: the author for chirps, the target for the others.
+var WebhookEventTypes = []string{
+	events.TypeChirpCreated,
+	events.TypeChirpDeleted,
+	events.TypeUserFollowed,
+	events.TypeUserMentioned,
+}
+
+type webhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+}
+
+type webhookEndpointResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	URL                 string     `json:"url"`
+	EventTypes          []string   `json:"event_types"`
+	Description         string     `json:"description"`
+	Enabled             bool       `json:"enabled"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type webhookDeliveryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	ResponseStatus *int32     `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// webhookPayload is the body of every delivery. It only carries ids, the
+// receiver fetches what it needs through the API.
+type webhookDeliveryJob struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+type webhookPayload struct {
+	ID        uuid.UUID          `json:"id"`
+	Type      string             `json:"type"`
+	CreatedAt time.Time          `json:"created_at"`
+	Data      webhookPayloadData `json:"data"`
+}
+
+type webhookPayloadData struct {
+	ActorID *uuid.UUID `json:"actor_id,omitempty"`
+	UserID  *uuid.UUID `json:"user_id,omitempty"`
+	ChirpID *uuid.UUID `json:"chirp_id,omitempty"`
+}
+
+func (cfg *ApiConfig) WebhooksCreate(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDFromContext(r.Context())
+
+	req := webhookEndpointRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	if err := validateWebhookEndpoint(req); err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	count, err := cfg.DbQueries.CountWebhookEndpointsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not create webhook")
+		return
+	}
+
+	if count >= MaxWebhookEndpoints {
+		respondWithError(w, r, 403, fmt.Sprintf("you can have at most %d webhooks", MaxWebhookEndpoints))
+		return
+	}
+
+	secret, err := auth.GenerateSecureToken()
+	if err != nil {
+		respondWithError(w, r, 500, "could not create webhook")
+		return
+	}
+
+	endpoint, err := cfg.DbQueries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:      userID,
+		Url:         req.URL,
+		Secret:      secret,
+		EventTypes:  uniqueStrings(req.EventTypes),
+		Description: req.Description,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not create webhook")
+		return
+	}
+
+	response := convertWebhookEndpoint(endpoint)
+	response.Secret = endpoint.Secret
+
+	respondWithJSON(w, 201, response)
+
+}
+
+func (cfg *ApiConfig) WebhooksList(w http.ResponseWriter, r *http.Request) {
+
+	endpoints, err := cfg.DbQueries.GetWebhookEndpointsForUser(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve webhooks")
+		return
+	}
+
+	response := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, convertWebhookEndpoint(endpoint))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) WebhooksUpdate(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	req := webhookEndpointRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
+
+	if err := validateWebhookEndpoint(req); err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	endpoint, err := cfg.DbQueries.UpdateWebhookEndpoint(r.Context(), database.UpdateWebhookEndpointParams{
+		ID:          endpoint.ID,
+		UserID:      endpoint.UserID,
+		Url:         req.URL,
+		EventTypes:  uniqueStrings(req.EventTypes),
+		Description: req.Description,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not update webhook")
+		return
+	}
+
+	respondWithJSON(w, 200, convertWebhookEndpoint(endpoint))
+
+}
+
+// WebhooksEnable turns an endpoint that was disabled after repeated failures
+// back on. Deliveries that were still pending are retried.
+func (cfg *ApiConfig) WebhooksEnable(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, r, 500, "could not enable webhook")
+		return
+	}
+	defer tx.Rollback()
+
+	queries := cfg.DbQueries.WithTx(tx)
+
+	if err := queries.EnableWebhookEndpoint(r.Context(), endpoint.ID); err != nil {
+		respondWithError(w, r, 500, "could not enable webhook")
+		return
+	}
+
+	// The jobs of these deliveries found the endpoint disabled and gave up.
+	pending, err := queries.GetPendingWebhookDeliveryIDs(r.Context(), endpoint.ID)
+	if err != nil {
+		respondWithError(w, r, 500, "could not enable webhook")
+		return
+	}
+
+	for _, deliveryID := range pending {
+		if err := cfg.queueWebhookDelivery(r.Context(), tx, deliveryID, time.Now()); err != nil {
+			respondWithError(w, r, 500, "could not enable webhook")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, r, 500, "could not enable webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) WebhooksDelete(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpoint.ID,
+		UserID: endpoint.UserID,
+	}); err != nil {
+		respondWithError(w, r, 500, "could not delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *ApiConfig) WebhooksDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	limit, err := parsePageSize(r, 50, 500)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	deliveries, err := cfg.DbQueries.GetWebhookDeliveriesForEndpoint(r.Context(), database.GetWebhookDeliveriesForEndpointParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, "could not retrieve deliveries")
+		return
+	}
+
+	response := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response = append(response, convertWebhookDelivery(delivery))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+// WebhooksTest queues a webhook.test event for the endpoint, whatever event
+// types it is subscribed to.
+func (cfg *ApiConfig) WebhooksTest(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if endpoint.DisabledAt.Valid {
+		respondWithError(w, r, 409, "webhook is disabled")
+		return
+	}
+
+	delivery, err := cfg.enqueueWebhook(r.Context(), endpoint, WebhookEventTest, webhookPayloadData{UserID: &endpoint.UserID})
+	if err != nil {
+		respondWithError(w, r, 500, "could not queue test event")
+		return
+	}
+
+	respondWithJSON(w, 202, convertWebhookDelivery(delivery))
+
+}
+
+func (cfg *ApiConfig) SubscribeWebhooks(bus events.Bus) {
+	for _, eventType := range WebhookEventTypes {
+		bus.Subscribe(eventType, cfg.queueWebhooks)
+	}
+}
+
+// queueWebhooks stores a delivery for every endpoint subscribed to the event.
+// Sending happens in a job, so slow receivers never block requests.
+func (cfg *ApiConfig) queueWebhooks(ctx context.Context, event events.Event) {
+
+	if event.Remote {
+		return
+	}
+
+	ownerID := event.UserID
+	if event.Type == events.TypeChirpCreated || event.Type == events.TypeChirpDeleted {
+		ownerID = event.ActorID
+	}
+
+	endpoints, err := cfg.DbQueries.GetActiveWebhookEndpointsForEvent(ctx, database.GetActiveWebhookEndpointsForEventParams{
+		UserID:    ownerID,
+		EventType: event.Type,
+	})
+	if err != nil {
+		log.Printf("could not look up webhooks for %s: %v", event.Type, err)
+		return
+	}
+
+	data := webhookPayloadData{
+		ActorID: optionalUUID(event.ActorID),
+		UserID:  optionalUUID(event.UserID),
+		ChirpID: optionalUUID(event.ChirpID),
+	}
+
+	for _, endpoint := range endpoints {
+		if _, err := cfg.enqueueWebhook(ctx, endpoint, event.Type, data); err != nil {
+			log.Printf("could not queue webhook %v for %s: %v", endpoint.ID, event.Type, err)
+		}
+	}
+
+}
+
+func (cfg *ApiConfig) enqueueWebhook(ctx context.Context, endpoint database.WebhookEndpoint, eventType string, data webhookPayloadData) (database.WebhookDelivery, error) {
+
+	payload := webhookPayload{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	defer tx.Rollback()
+
+	delivery, err := cfg.DbQueries.WithTx(tx).CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		ID:         payload.ID,
+		EndpointID: endpoint.ID,
+		EventType:  eventType,
+		Payload:    string(body),
+	})
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+
+	if err := cfg.queueWebhookDelivery(ctx, tx, delivery.ID, delivery.NextAttemptAt); err != nil {
+		return database.WebhookDelivery{}, err
+	}
+
+	return delivery, tx.Commit()
+
+}
+
+// queueWebhookDelivery queues a job for the next attempt of a delivery. The
+// delivery row keeps its own attempts and backoff, the job only triggers it.
+func (cfg *ApiConfig) queueWebhookDelivery(ctx context.Context, tx *sql.Tx, deliveryID uuid.UUID, runAt time.Time) error {
+	return cfg.Jobs.WithTx(tx).Enqueue(ctx, jobs.Job{
+		Kind:    JobDeliverWebhook,
+		Payload: webhookDeliveryJob{DeliveryID: deliveryID},
+		RunAt:   runAt,
+	})
+}
+
+// deliverWebhookJob sends a delivery that is due. Claiming it moves the next
+// attempt past the request timeout, so a second job for the same delivery
+// finds nothing to do, and the job is retried if its worker dies while
+// sending.
+func (cfg *ApiConfig) deliverWebhookJob(ctx context.Context, payload []byte) error {
+
+	job := webhookDeliveryJob{}
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	delivery, err := cfg.DbQueries.ClaimWebhookDelivery(ctx, database.ClaimWebhookDeliveryParams{
+		LeaseSeconds: int32(2 * WebhookTimeout / time.Second),
+		ID:           job.DeliveryID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return cfg.deliverWebhook(ctx, delivery)
+
+}
+
+func (cfg *ApiConfig) deliverWebhook(ctx context.Context, delivery database.WebhookDelivery) error {
+
+	endpoint, err := cfg.DbQueries.GetWebhookEndpointByID(ctx, delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+
+	statusCode, sendErr := cfg.sendWebhook(ctx, endpoint, delivery)
+
+	params := database.FinishWebhookDeliveryParams{
+		ID:            delivery.ID,
+		Status:        WebhookDeliveryDelivered,
+		NextAttemptAt: delivery.NextAttemptAt,
+	}
+
+	if statusCode != 0 {
+		params.ResponseStatus = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+
+	if sendErr == nil {
+		if err := cfg.DbQueries.ResetWebhookEndpointFailures(ctx, endpoint.ID); err != nil {
+			return err
+		}
+		return cfg.DbQueries.FinishWebhookDelivery(ctx, params)
+	}
+
+	params.LastError = sendErr.Error()
+	if delivery.Attempts >= WebhookMaxAttempts {
+		params.Status = WebhookDeliveryFailed
+	} else {
+		params.Status = WebhookDeliveryPending
+		params.NextAttemptAt = time.Now().UTC().Add(jobs.Backoff(int(delivery.Attempts), WebhookBackoffBase, WebhookBackoffMax))
+	}
+
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := cfg.DbQueries.WithTx(tx).FinishWebhookDelivery(ctx, params); err != nil {
+		return err
+	}
+
+	if params.Status == WebhookDeliveryPending {
+		if err := cfg.queueWebhookDelivery(ctx, tx, delivery.ID, params.NextAttemptAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	endpoint, err = cfg.DbQueries.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+		DisableAfter: WebhookDisableAfterFailures,
+		ID:           endpoint.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if endpoint.DisabledAt.Valid && endpoint.ConsecutiveFailures == WebhookDisableAfterFailures {
+		log.Printf("disabled webhook %v after %d failed deliveries", endpoint.ID, endpoint.ConsecutiveFailures)
+	}
+
+	return nil
+
+}
+
+// sendWebhook posts the payload and returns the response status, if any.
+func (cfg *ApiConfig) sendWebhook(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery) (int, error) {
+
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("Chirpy-Event", delivery.EventType)
+	req.Header.Set("Chirpy-Delivery", delivery.ID.String())
+	req.Header.Set(ChirpySignatureHeader, webhook.Sign([]string{endpoint.Secret}, time.Now(), body))
+
+	resp, err := cfg.WebhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+
+}
+
+func (cfg *ApiConfig) webhookEndpointFromPath(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+
+	endpointID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid webhook id")
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.DbQueries.GetWebhookEndpointByID(r.Context(), endpointID)
+	if err != nil || endpoint.UserID != userIDFromContext(r.Context()) {
+		respondWithError(w, r, 404, "webhook not found")
+		return database.WebhookEndpoint{}, false
+	}
+
+	return endpoint, true
+
+}
+
+// validateWebhookEndpoint rejects obviously internal targets early. The
+// delivery client checks the address it actually connects to as well.
+func validateWebhookEndpoint(req webhookEndpointRequest) error {
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return fmt.Errorf("url must be an absolute http or https url")
+	}
+
+	if addr, err := netip.ParseAddr(target.Hostname()); err == nil && netguard.IsBlocked(addr) {
+		return fmt.Errorf("url must point to a public address")
+	}
+
+	if len(req.EventTypes) == 0 {
+		return fmt.Errorf("event_types must not be empty")
+	}
+
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(WebhookEventTypes, eventType) {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+
+	if len(req.Description) > 200 {
+		return fmt.Errorf("description is longer than 200 characters")
+	}
+
+	return nil
+
+}
+
+func optionalUUID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+func uniqueStrings(values []string) []string {
+
+	unique := []string{}
+	for _, value := range values {
+		if !slices.Contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+
+}
+
+func convertWebhookEndpoint(endpoint database.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:                  endpoint.ID,
+		CreatedAt:           endpoint.CreatedAt,
+		UpdatedAt:           endpoint.UpdatedAt,
+		URL:                 endpoint.Url,
+		EventTypes:          endpoint.EventTypes,
+		Description:         endpoint.Description,
+		Enabled:             !endpoint.DisabledAt.Valid,
+		DisabledAt:          nullTimePtr(endpoint.DisabledAt),
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+	}
+}
+
+func convertWebhookDelivery(delivery database.WebhookDelivery) webhookDeliveryResponse {
+
+	response := webhookDeliveryResponse{
+		ID:          delivery.ID,
+		CreatedAt:   delivery.CreatedAt,
+		EventType:   delivery.EventType,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		LastError:   delivery.LastError,
+		DeliveredAt: nullTimePtr(delivery.DeliveredAt),
+	}
+
+	if delivery.Status == WebhookDeliveryPending {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+
+	if delivery.ResponseStatus.Valid {
+		response.ResponseStatus = &delivery.ResponseStatus.Int32
+	}
+
+	return response
+
+}
@@ -1,55 +1,273 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/sebasukodo/chirpy/internal/auth"
+	"github.com/sebasukodo/chirpy/internal/billing"
+	"github.com/sebasukodo/chirpy/internal/database"
 )
 
-type data struct {
-	UserID uuid.UUID `json:"user_id"`
-}
+const (
+	WebhookMaxBytes        = 64 << 10
+	WebhookProcessingLease = time.Minute
+	WebhookStatusProcessed = "processed"
+	WebhookStatusIgnored   = "ignored"
+	WebhookStatusFailed    = "failed"
+)
+
+var errWebhookInProgress = errors.New("webhook event is already being processed")
 
-type eventRequest struct {
-	Event string `json:"event"`
-	Data  data   `json:"data"`
+type webhookEventResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Provider    string     `json:"provider"`
+	EventID     string     `json:"event_id"`
+	EventType   string     `json:"event_type"`
+	Status      string     `json:"status"`
+	Deliveries  int32      `json:"deliveries"`
+	LastError   string     `json:"last_error,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
 }
 
+// VIP receives Polka webhooks on the URL Polka was originally set up with.
 func (cfg *ApiConfig) VIP(w http.ResponseWriter, r *http.Request) {
+	cfg.receiveBillingWebhook(w, r, "polka")
+}
+
+func (cfg *ApiConfig) BillingWebhook(w http.ResponseWriter, r *http.Request) {
+	cfg.receiveBillingWebhook(w, r, r.PathValue("provider"))
+}
 
-	key, err := auth.GetAPIKey(r.Header)
+// receiveBillingWebhook records every delivery of a provider, and retries of
+// an event that was already handled are acknowledged without processing it
+// again.
+func (cfg *ApiConfig) receiveBillingWebhook(w http.ResponseWriter, r *http.Request, providerName string) {
+
+	provider, ok := cfg.BillingProviders[providerName]
+	if !ok {
+		respondWithError(w, r, 404, "Not Found")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, WebhookMaxBytes))
 	if err != nil {
-		respondWithError(w, r, 401, "Access Denied")
+		respondWithError(w, r, 413, "Payload Too Large")
 		return
 	}
 
-	if key != cfg.PolkaApiKey {
+	if err := provider.Verify(r.Header, body); err != nil {
 		respondWithError(w, r, 401, "Access Denied")
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
+	billingEvent, err := provider.ParseEvent(r.Header, body)
+	ignored := errors.Is(err, billing.ErrIgnored)
+	if err != nil && !ignored {
+		respondWithError(w, r, 400, "could not decode json message")
+		return
+	}
 
-	eventRequestData := eventRequest{}
+	if billingEvent.ID == "" && !ignored {
+		respondWithError(w, r, 400, "event has no id")
+		return
+	}
 
-	if err := decoder.Decode(&eventRequestData); err != nil {
+	event, err := cfg.DbQueries.RecordWebhookEvent(r.Context(), recordWebhookEventParams(provider, billingEvent, body))
+	if err != nil {
 		respondWithError(w, r, 500, "Internal Error")
 		return
 	}
 
-	if eventRequestData.Event != "user.upgraded" {
+	err = cfg.processWebhookEvent(r.Context(), event)
+	switch {
+	case err == nil:
 		w.WriteHeader(204)
+	case errors.Is(err, errUserNotFound):
+		respondWithError(w, r, 404, "User not found")
+	case errors.Is(err, errWebhookInProgress):
+		respondWithError(w, r, 409, "Event is being processed")
+	default:
+		log.Printf("could not process webhook event %v: %v", event.ID, err)
+		respondWithError(w, r, 500, "Internal Error")
+	}
+
+}
+
+// recordWebhookEventParams gives ignored events without a provider id an id
+// of their own, they are only recorded.
+func recordWebhookEventParams(provider billing.Provider, event billing.Event, body []byte) database.RecordWebhookEventParams {
+
+	eventID := event.ID
+	if eventID == "" {
+		eventID = "delivery-" + uuid.NewString()
+	}
+
+	return database.RecordWebhookEventParams{
+		Provider:  provider.Name(),
+		EventID:   eventID,
+		EventType: event.Type,
+		Payload:   string(body),
+	}
+
+}
+
+// processWebhookEvent handles a recorded event unless it was handled before
+// or is being handled right now. It is used for deliveries and replays. An
+// event whose processing was cut off can be claimed again once its lease
+// has run out.
+func (cfg *ApiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+
+	if event.Status == WebhookStatusProcessed || event.Status == WebhookStatusIgnored {
+		return nil
+	}
+
+	claimed, err := cfg.DbQueries.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{
+		ID:           event.ID,
+		LeaseSeconds: int32(WebhookProcessingLease / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return errWebhookInProgress
+	}
+
+	status, processErr := cfg.applyBillingEvent(ctx, event)
+
+	lastError := ""
+	if processErr != nil {
+		status, lastError = WebhookStatusFailed, processErr.Error()
+	}
+
+	if err := cfg.DbQueries.FinishWebhookEvent(context.WithoutCancel(ctx), database.FinishWebhookEventParams{
+		ID:        event.ID,
+		Status:    status,
+		LastError: lastError,
+	}); err != nil {
+		return err
+	}
+
+	return processErr
+
+}
+
+// applyBillingEvent parses the stored payload again, so replays use the
+// current provider code. Headers are not stored, which is fine because
+// providers only read the event id from them.
+func (cfg *ApiConfig) applyBillingEvent(ctx context.Context, event database.WebhookEvent) (string, error) {
+
+	provider, ok := cfg.BillingProviders[event.Provider]
+	if !ok {
+		return "", fmt.Errorf("unknown billing provider %q", event.Provider)
+	}
+
+	billingEvent, err := provider.ParseEvent(http.Header{}, []byte(event.Payload))
+	if errors.Is(err, billing.ErrIgnored) {
+		return WebhookStatusIgnored, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := cfg.applySubscriptionChange(ctx, provider.Name(), billingEvent); err != nil {
+		return "", err
+	}
+
+	return WebhookStatusProcessed, nil
+
+}
+
+// ReplayWebhookEvent processes a stored event again, for events that failed.
+func (cfg *ApiConfig) ReplayWebhookEvent(ctx context.Context, eventID uuid.UUID) (database.WebhookEvent, error) {
+
+	event, err := cfg.DbQueries.GetWebhookEventByID(ctx, eventID)
+	if err != nil {
+		return database.WebhookEvent{}, err
+	}
+
+	processErr := cfg.processWebhookEvent(ctx, event)
+
+	event, err = cfg.DbQueries.GetWebhookEventByID(ctx, eventID)
+	if err != nil {
+		return database.WebhookEvent{}, err
+	}
+
+	return event, processErr
+
+}
+
+func (cfg *ApiConfig) AdminWebhookEvents(w http.ResponseWriter, r *http.Request) {
+
+	pageSize, err := parsePageSize(r, 50, 500)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
 		return
 	}
 
-	_, err = cfg.DbQueries.UpdateUserVIP(r.Context(), eventRequestData.Data.UserID)
+	events, err := cfg.DbQueries.GetRecentWebhookEvents(r.Context(), database.GetRecentWebhookEventsParams{
+		Status:   r.URL.Query().Get("status"),
+		PageSize: int32(pageSize),
+	})
 	if err != nil {
-		respondWithError(w, r, 404, "User not found")
+		respondWithError(w, r, 500, "could not retrieve webhook events")
+		return
+	}
+
+	response := make([]webhookEventResponse, 0, len(events))
+	for _, event := range events {
+		response = append(response, convertWebhookEvent(event))
+	}
+
+	respondWithJSON(w, 200, response)
+
+}
+
+func (cfg *ApiConfig) AdminWebhookEventReplay(w http.ResponseWriter, r *http.Request) {
+
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, r, 400, "invalid event id")
 		return
 	}
 
-	w.WriteHeader(204)
+	event, err := cfg.ReplayWebhookEvent(r.Context(), eventID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		respondWithError(w, r, 404, "webhook event not found")
+		return
+	case errors.Is(err, errWebhookInProgress):
+		respondWithError(w, r, 409, err.Error())
+		return
+	case event.ID == uuid.Nil:
+		respondWithError(w, r, 500, "could not replay webhook event")
+		return
+	}
+
+	// A failed replay is reported through the status of the event.
+	respondWithJSON(w, 200, convertWebhookEvent(event))
 
 }
+
+func convertWebhookEvent(event database.WebhookEvent) webhookEventResponse {
+	return webhookEventResponse{
+		ID:          event.ID,
+		CreatedAt:   event.CreatedAt,
+		UpdatedAt:   event.UpdatedAt,
+		Provider:    event.Provider,
+		EventID:     event.EventID,
+		EventType:   event.EventType,
+		Status:      event.Status,
+		Deliveries:  event.Deliveries,
+		LastError:   event.LastError,
+		ProcessedAt: nullTimePtr(event.ProcessedAt),
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+const PurgeInterval = time.Hour
+
+// RegisterJobs tells the job queue about every kind of background work. It
+// has to be called in every process that runs workers.
+func (cfg *ApiConfig) RegisterJobs() {
+	cfg.Jobs.Register(JobBuildDataExport, cfg.buildDataExportJob)
+	cfg.Jobs.Register(JobRunImport, cfg.runImportJob)
+	cfg.Jobs.Register(JobFetchLinkPreview, cfg.fetchLinkPreviewJob)
+	cfg.Jobs.Register(JobPublishDraft, cfg.publishDraftJob)
+	cfg.Jobs.Register(JobDeliverWebhook, cfg.deliverWebhookJob)
+
+	cfg.Jobs.Every("purge_deactivated_users", PurgeInterval, cfg.PurgeDeactivatedUsers)
+	cfg.Jobs.Every("cleanup_expired_exports", PurgeInterval, cfg.CleanupExpiredExports)
+	cfg.Jobs.Every("cleanup_orphaned_media", PurgeInterval, cfg.CleanupOrphanedMedia)
+	cfg.Jobs.Every("cleanup_expired_mutes", PurgeInterval, cfg.CleanupExpiredMutes)
+	cfg.Jobs.Every("expire_subscriptions", SubscriptionExpiryInterval, cfg.ExpireSubscriptions)
+	cfg.Jobs.Every("revoke_expired_session_ids", PurgeInterval, cfg.DbQueries.RevokeAllExpiredSessionIDs)
+	cfg.Jobs.Every("revoke_expired_refresh_tokens", PurgeInterval, cfg.DbQueries.RevokeAllExpiredRefreshToken)
+	cfg.Jobs.Every("prune_jobs", PurgeInterval, cfg.PruneJobs)
+}
+
+func (cfg *ApiConfig) PurgeDeactivatedUsers(ctx context.Context) error {
+
+	cutoff := time.Now().UTC().Add(-cfg.AccountGracePeriod)
+
+	purged, err := cfg.DbQueries.PurgeDeactivatedUsers(ctx, sql.NullTime{Time: cutoff, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		log.Printf("purged %d deactivated users", purged)
+	}
+
+	// Purging removes memberships and keeps messages with an empty sender.
+	// Conversations nobody is left in can go.
+	if _, err := cfg.DbQueries.DeleteEmptyConversations(ctx); err != nil {
+		return err
+	}
+
+	return nil
+
+}
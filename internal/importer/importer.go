@@ -0,0 +1,188 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policies for bodies longer than the chirp length limit.
+const (
+	PolicyReject   = "reject"
+	PolicyTruncate = "truncate"
+	PolicySplit    = "split"
+	PolicySkip     = "skip"
+)
+
+type Options struct {
+	MaxLength int
+	Policy    string
+	Clean     func(string) string
+	// Resume is the saved progress of an interrupted run. The items it
+	// processed are not stored again.
+	Resume Progress
+}
+
+type Progress struct {
+	Total     int
+	Processed int
+	Imported  int
+	Skipped   int
+	Failed    int
+}
+
+type ItemError struct {
+	Ref     string
+	Message string
+}
+
+type StoreFunc func(ctx context.Context, item Item, body string, createdAt time.Time) error
+
+type ReportFunc func(progress Progress, itemErr *ItemError)
+
+func ValidPolicy(policy string) bool {
+	switch policy {
+	case PolicyReject, PolicyTruncate, PolicySplit, PolicySkip:
+		return true
+	}
+	return false
+}
+
+// Run stores every item in order and calls report after each one. A failing
+// item never stops the import, it is only reported.
+func Run(ctx context.Context, items []Item, opts Options, store StoreFunc, report ReportFunc) Progress {
+
+	progress := opts.Resume
+	progress.Total = len(items)
+
+	for _, item := range items[min(progress.Processed, len(items)):] {
+		if ctx.Err() != nil {
+			break
+		}
+
+		var itemErr *ItemError
+
+		bodies, skip, err := ApplyPolicy(item.Body, opts.MaxLength, opts.Policy)
+
+		switch {
+		case item.CreatedAt.IsZero():
+			itemErr = &ItemError{Ref: item.Ref, Message: "missing or invalid timestamp"}
+		case item.CreatedAt.After(time.Now()):
+			// Future chirps would stay on top of every timeline.
+			itemErr = &ItemError{Ref: item.Ref, Message: "timestamp is in the future"}
+		case err != nil:
+			itemErr = &ItemError{Ref: item.Ref, Message: err.Error()}
+		case skip:
+			progress.Skipped++
+		default:
+			for i, body := range bodies {
+				if opts.Clean != nil {
+					body = opts.Clean(body)
+				}
+
+				// Parts of a split post keep their order within the original second.
+				if err := store(ctx, item, body, item.CreatedAt.Add(time.Duration(i)*time.Microsecond)); err != nil {
+					itemErr = &ItemError{Ref: item.Ref, Message: fmt.Sprintf("could not store chirp: %v", err)}
+					break
+				}
+			}
+		}
+
+		if itemErr != nil {
+			progress.Failed++
+		} else if !skip {
+			progress.Imported++
+		}
+
+		progress.Processed++
+
+		if report != nil {
+			report(progress, itemErr)
+		}
+	}
+
+	return progress
+
+}
+
+func ApplyPolicy(body string, maxLength int, policy string) ([]string, bool, error) {
+
+	body = strings.TrimSpace(body)
+
+	if body == "" {
+		return nil, false, fmt.Errorf("empty body")
+	}
+
+	if len(body) <= maxLength {
+		return []string{body}, false, nil
+	}
+
+	switch policy {
+	case PolicyTruncate:
+		return []string{truncate(body, maxLength)}, false, nil
+	case PolicySplit:
+		return split(body, maxLength), false, nil
+	case PolicySkip:
+		return nil, true, nil
+	}
+
+	return nil, false, fmt.Errorf("body is %d characters long, the limit is %d", len(body), maxLength)
+
+}
+
+func truncate(body string, maxLength int) string {
+
+	const ellipsis = "…"
+
+	cut := maxLength - len(ellipsis)
+	for cut > 0 && !isBoundary(body, cut) {
+		cut--
+	}
+
+	return strings.TrimSpace(body[:cut]) + ellipsis
+
+}
+
+func split(body string, maxLength int) []string {
+
+	parts := []string{}
+	current := ""
+
+	for _, word := range strings.Fields(body) {
+		for len(word) > maxLength {
+			cut := maxLength
+			for cut > 0 && !isBoundary(word, cut) {
+				cut--
+			}
+			if current != "" {
+				parts = append(parts, current)
+				current = ""
+			}
+			parts = append(parts, word[:cut])
+			word = word[cut:]
+		}
+
+		switch {
+		case current == "":
+			current = word
+		case len(current)+1+len(word) <= maxLength:
+			current += " " + word
+		default:
+			parts = append(parts, current)
+			current = word
+		}
+	}
+
+	if current != "" {
+		parts = append(parts, current)
+	}
+
+	return parts
+
+}
+
+// isBoundary reports whether cutting at i keeps multi-byte runes intact.
+func isBoundary(s string, i int) bool {
+	return i >= len(s) || s[i]&0xC0 != 0x80
+}
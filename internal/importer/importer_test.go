@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const tweetsJS = `window.YTD.tweets.part0 = [
+  {"tweet": {"id_str": "2", "full_text": "second &amp; last", "created_at": "Thu Oct 11 08:00:00 +0000 2018"}},
+  {"tweet": {"id_str": "1", "full_text": "first", "created_at": "Wed Oct 10 20:19:24 +0000 2018"}},
+  {"tweet": {"id_str": "3", "full_text": "no date", "created_at": "yesterday"}}
+]`
+
+func TestParseTweetsJS(t *testing.T) {
+
+	source, items, err := Parse([]byte(tweetsJS))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if source != SourceTwitter {
+		t.Errorf("expected source %q, got %q", SourceTwitter, source)
+	}
+
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(items))
+	}
+
+	if items[1].Ref != "1" || !items[1].CreatedAt.Equal(time.Date(2018, 10, 10, 20, 19, 24, 0, time.UTC)) {
+		t.Errorf("unexpected first dated item: %+v", items[1])
+	}
+
+	if items[2].Body != "second & last" {
+		t.Errorf("expected html entities to be decoded, got %q", items[2].Body)
+	}
+
+}
+
+func TestRunPolicies(t *testing.T) {
+
+	long := strings.Repeat("word ", 40)
+	items := []Item{
+		{Ref: "short", Body: "hello", CreatedAt: time.Now()},
+		{Ref: "long", Body: long, CreatedAt: time.Now()},
+		{Ref: "undated", Body: "hello"},
+		{Ref: "future", Body: "hello", CreatedAt: time.Now().Add(time.Hour)},
+	}
+
+	type testCase struct {
+		policy   string
+		stored   int
+		imported int
+		skipped  int
+		failed   int
+	}
+
+	runCases := []testCase{
+		{policy: PolicyReject, stored: 1, imported: 1, failed: 3},
+		{policy: PolicySkip, stored: 1, imported: 1, skipped: 1, failed: 2},
+		{policy: PolicyTruncate, stored: 2, imported: 2, failed: 2},
+		{policy: PolicySplit, stored: 3, imported: 2, failed: 2},
+	}
+
+	for _, test := range runCases {
+		stored := []string{}
+		store := func(ctx context.Context, item Item, body string, createdAt time.Time) error {
+			if len(body) > 140 {
+				t.Errorf("%s: stored body longer than limit: %d", test.policy, len(body))
+			}
+			stored = append(stored, body)
+			return nil
+		}
+
+		progress := Run(context.Background(), items, Options{MaxLength: 140, Policy: test.policy}, store, nil)
+
+		if len(stored) != test.stored || progress.Imported != test.imported || progress.Skipped != test.skipped || progress.Failed != test.failed {
+			t.Errorf("%s: unexpected result: stored=%d progress=%+v", test.policy, len(stored), progress)
+		}
+
+		if progress.Processed != len(items) {
+			t.Errorf("%s: expected %d processed, got %d", test.policy, len(items), progress.Processed)
+		}
+	}
+
+}
+
+func TestRunResume(t *testing.T) {
+
+	items := []Item{
+		{Ref: "1", Body: "one", CreatedAt: time.Now()},
+		{Ref: "2", Body: "two", CreatedAt: time.Now()},
+		{Ref: "3", Body: "three", CreatedAt: time.Now()},
+	}
+
+	stored := []string{}
+	store := func(ctx context.Context, item Item, body string, createdAt time.Time) error {
+		stored = append(stored, item.Ref)
+		return nil
+	}
+
+	progress := Run(context.Background(), items, Options{
+		MaxLength: 140,
+		Policy:    PolicyReject,
+		Resume:    Progress{Processed: 2, Imported: 1, Failed: 1},
+	}, store, nil)
+
+	if len(stored) != 1 || stored[0] != "3" {
+		t.Errorf("expected only the last item to be stored, got %v", stored)
+	}
+
+	if progress.Processed != 3 || progress.Imported != 2 || progress.Failed != 1 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+
+}
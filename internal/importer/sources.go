@@ -0,0 +1,154 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sebasukodo/chirpy/internal/archive"
+)
+
+const (
+	SourceChirpy  = "chirpy"
+	SourceTwitter = "twitter"
+)
+
+type Item struct {
+	Ref       string    `json:"ref"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	// Visibility is empty for sources that do not have one.
+	Visibility string `json:"visibility,omitempty"`
+}
+
+type twitterEntry struct {
+	Tweet struct {
+		ID        string `json:"id_str"`
+		FullText  string `json:"full_text"`
+		Text      string `json:"text"`
+		CreatedAt string `json:"created_at"`
+	} `json:"tweet"`
+}
+
+// Parse detects the archive type and returns the posts it contains. It accepts
+// Chirpy export zips, Twitter archive zips and bare tweets.js files.
+func Parse(data []byte) (string, []Item, error) {
+
+	if !bytes.HasPrefix(data, []byte("PK")) {
+		items, err := ParseTweetsJS(bytes.NewReader(data))
+		if err != nil {
+			return "", nil, err
+		}
+		return SourceTwitter, items, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, fmt.Errorf("not a zip archive: %w", err)
+	}
+
+	if _, err := zr.Open("manifest.json"); err == nil {
+		exported, err := archive.Read(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return "", nil, err
+		}
+
+		items := make([]Item, 0, len(exported.Chirps))
+		for _, chirp := range exported.Chirps {
+			items = append(items, Item{
+				Ref:        chirp.ID.String(),
+				Body:       chirp.Body,
+				CreatedAt:  chirp.CreatedAt,
+				Visibility: chirp.Visibility,
+			})
+		}
+		return SourceChirpy, items, nil
+	}
+
+	items := []Item{}
+	found := false
+	for _, file := range zr.File {
+		name := path.Base(file.Name)
+		if !(strings.HasPrefix(name, "tweets") || strings.HasPrefix(name, "tweet")) || !strings.HasSuffix(name, ".js") {
+			continue
+		}
+
+		rc, err := archive.Open(file)
+		if err != nil {
+			return "", nil, err
+		}
+
+		parsed, err := ParseTweetsJS(rc)
+		rc.Close()
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+
+		items = append(items, parsed...)
+		found = true
+	}
+
+	if !found {
+		return "", nil, fmt.Errorf("archive contains neither a Chirpy manifest nor tweets.js")
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	return SourceTwitter, items, nil
+
+}
+
+// ParseTweetsJS reads the "window.YTD.tweets.part0 = [...]" files of a Twitter
+// data archive.
+func ParseTweetsJS(r io.Reader) ([]Item, error) {
+
+	data, err := io.ReadAll(archive.LimitReader(r, archive.MaxFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("could not read tweets.js: %w", err)
+	}
+
+	start := bytes.IndexByte(data, '[')
+	if start == -1 {
+		return nil, fmt.Errorf("tweets.js does not contain a tweet list")
+	}
+
+	entries := []twitterEntry{}
+	if err := json.Unmarshal(data[start:], &entries); err != nil {
+		return nil, fmt.Errorf("could not decode tweets.js: %w", err)
+	}
+
+	items := make([]Item, 0, len(entries))
+	for _, entry := range entries {
+		text := entry.Tweet.FullText
+		if text == "" {
+			text = entry.Tweet.Text
+		}
+
+		createdAt, err := time.Parse(time.RubyDate, entry.Tweet.CreatedAt)
+		if err != nil {
+			createdAt = time.Time{}
+		}
+
+		items = append(items, Item{
+			Ref:       entry.Tweet.ID,
+			Body:      html.UnescapeString(text),
+			CreatedAt: createdAt.UTC(),
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	return items, nil
+
+}
@@ -0,0 +1,277 @@
+// Package jobs is a job queue stored in Postgres. Workers claim due jobs with
+// FOR UPDATE SKIP LOCKED, so any number of processes can share one table.
+// A job that fails is retried with backoff until it runs out of attempts and
+// is marked dead.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sebasukodo/chirpy/internal/database"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusDead    = "dead"
+
+	DefaultMaxAttempts  = 5
+	DefaultLease        = 5 * time.Minute
+	DefaultPollInterval = time.Second
+
+	BackoffBase = 10 * time.Second
+	BackoffMax  = time.Hour
+)
+
+// Handler runs a job. payload is the JSON the job was enqueued with.
+type Handler func(ctx context.Context, payload []byte) error
+
+type Job struct {
+	Kind string
+	// Payload is stored as JSON.
+	Payload any
+	// UniqueKey makes Enqueue a no-op while a job of the same kind and key
+	// exists. Finished jobs keep their key until they are pruned.
+	UniqueKey string
+	// RunAt defaults to now.
+	RunAt       time.Time
+	MaxAttempts int
+}
+
+type Queue struct {
+	Queries *database.Queries
+	// Lease is how long a job may run. A job whose worker died is picked up
+	// again once its lease is over.
+	Lease        time.Duration
+	PollInterval time.Duration
+
+	handlers  map[string]Handler
+	schedules []schedule
+}
+
+type schedule struct {
+	kind     string
+	interval time.Duration
+}
+
+func New(queries *database.Queries) *Queue {
+	return &Queue{
+		Queries:      queries,
+		Lease:        DefaultLease,
+		PollInterval: DefaultPollInterval,
+		handlers:     map[string]Handler{},
+	}
+}
+
+// Register sets the handler for a kind. Workers only claim kinds they have a
+// handler for.
+func (q *Queue) Register(kind string, handler Handler) {
+	q.handlers[kind] = handler
+}
+
+// Every runs job once per interval. Every process running workers schedules
+// it, and the unique key of the interval makes sure only one of them runs it.
+// A failed run is not retried, the next interval takes care of it.
+func (q *Queue) Every(kind string, interval time.Duration, job func(context.Context) error) {
+	q.Register(kind, func(ctx context.Context, payload []byte) error {
+		return job(ctx)
+	})
+	q.schedules = append(q.schedules, schedule{kind: kind, interval: interval})
+}
+
+// WithTx returns a queue that enqueues within tx, so the job only exists if
+// tx commits.
+func (q *Queue) WithTx(tx *sql.Tx) *Queue {
+	queue := *q
+	queue.Queries = q.Queries.WithTx(tx)
+	return &queue
+}
+
+func (q *Queue) Enqueue(ctx context.Context, job Job) error {
+
+	payload := []byte("{}")
+	if job.Payload != nil {
+		var err error
+		payload, err = json.Marshal(job.Payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+
+	_, err := q.Queries.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        job.Kind,
+		Payload:     string(payload),
+		UniqueKey:   sql.NullString{String: job.UniqueKey, Valid: job.UniqueKey != ""},
+		MaxAttempts: int32(job.MaxAttempts),
+		RunAt:       job.RunAt.UTC(),
+	})
+
+	return err
+
+}
+
+// Run schedules recurring jobs and works on the queue with the given number
+// of workers. It returns once ctx is canceled and running jobs are finished.
+func (q *Queue) Run(ctx context.Context, workers int) {
+
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var wg sync.WaitGroup
+
+	for _, s := range q.schedules {
+		wg.Go(func() { q.schedule(ctx, s) })
+	}
+
+	for range workers {
+		wg.Go(func() { q.work(ctx, kinds) })
+	}
+
+	wg.Wait()
+
+}
+
+func (q *Queue) schedule(ctx context.Context, s schedule) {
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := q.Enqueue(ctx, Job{
+			Kind:        s.kind,
+			UniqueKey:   SlotKey(time.Now(), s.interval),
+			MaxAttempts: 1,
+		}); err != nil && ctx.Err() == nil {
+			log.Printf("could not schedule %s: %v", s.kind, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+}
+
+func (q *Queue) work(ctx context.Context, kinds []string) {
+
+	for {
+		job, err := q.Queries.ClaimJob(ctx, database.ClaimJobParams{
+			LeaseSeconds: int32(q.Lease / time.Second),
+			Kinds:        kinds,
+		})
+		if err == nil {
+			q.process(ctx, job)
+			continue
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+			log.Printf("could not claim job: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.PollInterval):
+		}
+	}
+
+}
+
+// process runs a claimed job and records the outcome. A job that already
+// started lets its handler finish when ctx is canceled, within the lease.
+func (q *Queue) process(ctx context.Context, job database.Job) {
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), q.Lease)
+	defer cancel()
+
+	var err error
+	if job.Attempts > job.MaxAttempts {
+		// Claimed again after its lease ran out on the last attempt.
+		err = errors.New("lease expired")
+	} else {
+		err = q.run(ctx, job)
+	}
+
+	switch {
+	case err == nil:
+		err = q.Queries.CompleteJob(ctx, job.ID)
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("job %s %v failed for good: %v", job.Kind, job.ID, err)
+		err = q.Queries.KillJob(ctx, database.KillJobParams{
+			ID:        job.ID,
+			LastError: err.Error(),
+		})
+	default:
+		err = q.Queries.RetryJob(ctx, database.RetryJobParams{
+			ID:        job.ID,
+			RunAt:     time.Now().UTC().Add(Backoff(int(job.Attempts), BackoffBase, BackoffMax)),
+			LastError: err.Error(),
+		})
+	}
+
+	if err != nil {
+		log.Printf("could not finish job %v: %v", job.ID, err)
+	}
+
+}
+
+func (q *Queue) run(ctx context.Context, job database.Job) (err error) {
+
+	handler, ok := q.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for %q", job.Kind)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, []byte(job.Payload))
+
+}
+
+// SlotKey names the interval t falls into, so a recurring job is enqueued
+// once per interval no matter how many processes schedule it.
+func SlotKey(t time.Time, interval time.Duration) string {
+	return t.UTC().Truncate(interval).Format(time.RFC3339)
+}
+
+// Backoff is how long to wait after the given failed attempt, starting at 1.
+// The delay starts at base and doubles with every attempt up to max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+
+	return delay
+
+}
@@ -0,0 +1,215 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	MaxPixels     = 40_000_000
+	ThumbnailSize = 320
+)
+
+var ErrUnsupportedType = errors.New("unsupported image type, use jpeg, png or gif")
+
+type Image struct {
+	ContentType   string
+	Data          []byte
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// Process sniffs the type of an uploaded image, removes its metadata and
+// renders a thumbnail. JPEGs with an EXIF orientation are rotated upright,
+// since the orientation tag is dropped with the rest of the EXIF block.
+func Process(data []byte) (Image, error) {
+
+	contentType := http.DetectContentType(data)
+
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("could not read image: %w", err)
+	}
+
+	if config.Width*config.Height > MaxPixels {
+		return Image{}, fmt.Errorf("image is larger than %d megapixels", MaxPixels/1_000_000)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("could not decode image: %w", err)
+	}
+
+	img := toRGBA(decoded)
+
+	switch contentType {
+	case "image/jpeg":
+		stripped, orientation, err := StripJPEG(data)
+		if err != nil {
+			return Image{}, err
+		}
+
+		data = stripped
+		if orientation > 1 {
+			img = orient(img, orientation)
+			data, err = encode(img, contentType, 90)
+			if err != nil {
+				return Image{}, err
+			}
+		}
+	case "image/png":
+		data, err = StripPNG(data)
+		if err != nil {
+			return Image{}, err
+		}
+	}
+
+	thumbnailType := "image/png"
+	if contentType == "image/jpeg" {
+		thumbnailType = "image/jpeg"
+	}
+
+	thumbnail, err := encode(Thumbnail(img, ThumbnailSize), thumbnailType, 80)
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		ContentType:   contentType,
+		Data:          data,
+		Width:         img.Bounds().Dx(),
+		Height:        img.Bounds().Dy(),
+		Thumbnail:     thumbnail,
+		ThumbnailType: thumbnailType,
+	}, nil
+
+}
+
+// Thumbnail scales img down to fit into a size x size box by averaging the
+// source pixels covered by each target pixel. Smaller images are returned as is.
+func Thumbnail(img *image.RGBA, size int) *image.RGBA {
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	thumbWidth, thumbHeight := size, height*size/width
+	if height > width {
+		thumbWidth, thumbHeight = width*size/height, size
+	}
+	thumbWidth, thumbHeight = max(thumbWidth, 1), max(thumbHeight, 1)
+
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+
+	for ty := 0; ty < thumbHeight; ty++ {
+		y0, y1 := ty*height/thumbHeight, max((ty+1)*height/thumbHeight, ty*height/thumbHeight+1)
+		for tx := 0; tx < thumbWidth; tx++ {
+			x0, x1 := tx*width/thumbWidth, max((tx+1)*width/thumbWidth, tx*width/thumbWidth+1)
+
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := img.Pix[y*img.Stride:]
+				for x := x0; x < x1; x++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[x*4+c])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			offset := ty*thumb.Stride + tx*4
+			for c := 0; c < 4; c++ {
+				thumb.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+
+	return thumb
+
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	return rgba
+
+}
+
+// orient applies one of the eight EXIF orientations to img.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		outWidth, outHeight = height, width
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, outWidth, outHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			default:
+				dx, dy = x, y
+			}
+			copy(out.Pix[dy*out.Stride+dx*4:dy*out.Stride+dx*4+4], img.Pix[y*img.Stride+x*4:y*img.Stride+x*4+4])
+		}
+	}
+
+	return out
+
+}
+
+func encode(img image.Image, contentType string, quality int) ([]byte, error) {
+
+	buf := bytes.Buffer{}
+
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+
+}
@@ -0,0 +1,118 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int) *image.RGBA {
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+
+	return img
+
+}
+
+// jpegWithOrientation encodes a JPEG and inserts an EXIF block right after SOI.
+func jpegWithOrientation(t *testing.T, width, height int, orientation uint16) []byte {
+
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, testImage(width, height), nil); err != nil {
+		t.Fatalf("could not encode jpeg: %v", err)
+	}
+
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	exif = append(exif, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	exif = binary.BigEndian.AppendUint16(exif, orientation)
+	exif = append(exif, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(exif)+2))
+	segment = append(segment, exif...)
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+
+}
+
+func TestProcessJPEG(t *testing.T) {
+
+	data := jpegWithOrientation(t, 800, 400, 6)
+
+	img, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Errorf("expected EXIF data to be stripped")
+	}
+
+	if img.Width != 400 || img.Height != 800 {
+		t.Errorf("expected image to be rotated to 400x800, got %dx%d", img.Width, img.Height)
+	}
+
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("could not decode thumbnail: %v", err)
+	}
+
+	if thumb.Width != 160 || thumb.Height != ThumbnailSize {
+		t.Errorf("expected 160x%d thumbnail, got %dx%d", ThumbnailSize, thumb.Width, thumb.Height)
+	}
+
+}
+
+func TestProcessPNG(t *testing.T) {
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, testImage(50, 30)); err != nil {
+		t.Fatalf("could not encode png: %v", err)
+	}
+
+	// Insert a tEXt chunk after IHDR (8 byte signature + 25 byte chunk).
+	text := []byte("tEXtComment\x00secret location")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)-4))
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(text))
+
+	data := buf.Bytes()
+	data = append(append(append([]byte{}, data[:33]...), chunk...), data[33:]...)
+
+	img, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if bytes.Contains(img.Data, []byte("secret location")) {
+		t.Errorf("expected text chunk to be stripped")
+	}
+
+	if _, err := png.Decode(bytes.NewReader(img.Data)); err != nil {
+		t.Errorf("stripped png does not decode: %v", err)
+	}
+
+	if img.Width != 50 || img.Height != 30 || img.ThumbnailType != "image/png" {
+		t.Errorf("unexpected result: %dx%d %s", img.Width, img.Height, img.ThumbnailType)
+	}
+
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+
+	if _, err := Process([]byte("<html><body>hi</body></html>")); err != ErrUnsupportedType {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+
+}
@@ -0,0 +1,174 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("malformed image data")
+
+// StripJPEG removes EXIF, XMP, IPTC and comment segments from a JPEG without
+// re-encoding it and returns the EXIF orientation it found (0 if none).
+// JFIF, ICC profile and Adobe segments are kept since they affect rendering.
+func StripJPEG(data []byte) ([]byte, int, error) {
+
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, errMalformed
+	}
+
+	out := bytes.Buffer{}
+	out.Grow(len(data))
+	out.Write(data[:2])
+
+	orientation := 0
+	i := 2
+
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, 0, errMalformed
+		}
+
+		// Markers may be preceded by any number of fill bytes.
+		for i < len(data) && data[i] == 0xFF {
+			i++
+		}
+		if i >= len(data) {
+			return nil, 0, errMalformed
+		}
+
+		marker := data[i]
+		i++
+
+		if marker == 0xD9 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write([]byte{0xFF, marker})
+			continue
+		}
+
+		if i+2 > len(data) {
+			return nil, 0, errMalformed
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i:]))
+		if length < 2 || i+length > len(data) {
+			return nil, 0, errMalformed
+		}
+
+		segment := data[i : i+length]
+		i += length
+
+		// Entropy coded data follows the start of scan, copy the rest verbatim.
+		if marker == 0xDA {
+			out.Write([]byte{0xFF, marker})
+			out.Write(segment)
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		}
+
+		switch {
+		case marker == 0xE1:
+			if o := exifOrientation(segment[2:]); o != 0 {
+				orientation = o
+			}
+			continue
+		case marker == 0xED, marker == 0xFE:
+			continue
+		}
+
+		out.Write([]byte{0xFF, marker})
+		out.Write(segment)
+	}
+
+	return nil, 0, errMalformed
+
+}
+
+// exifOrientation reads the orientation tag from IFD0 of an APP1 payload.
+func exifOrientation(payload []byte) int {
+
+	if !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+		return 0
+	}
+
+	tiff := payload[6:]
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+
+	entries := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < entries; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 0
+		}
+	}
+
+	return 0
+
+}
+
+// StripPNG drops the ancillary chunks that carry metadata (eXIf, text and
+// timestamps) and keeps everything needed to render the image.
+func StripPNG(data []byte) ([]byte, error) {
+
+	const signature = "\x89PNG\r\n\x1a\n"
+
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errMalformed
+	}
+
+	out := bytes.Buffer{}
+	out.Grow(len(data))
+	out.WriteString(signature)
+
+	i := len(signature)
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) || end < i {
+			return nil, errMalformed
+		}
+
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[i:end])
+		}
+
+		if string(data[i+4:i+8]) == "IEND" {
+			return out.Bytes(), nil
+		}
+
+		i = end
+	}
+
+	return nil, errMalformed
+
+}
@@ -0,0 +1,64 @@
+package netguard
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsBlocked(t *testing.T) {
+
+	blocked := []string{"127.0.0.1", "10.1.2.3", "172.20.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "::1", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "0.0.0.0"}
+	allowed := []string{"93.184.216.34", "1.1.1.1", "2606:4700:4700::1111"}
+
+	for _, ip := range blocked {
+		if !IsBlocked(netip.MustParseAddr(ip)) {
+			t.Errorf("expected %s to be blocked", ip)
+		}
+	}
+
+	for _, ip := range allowed {
+		if IsBlocked(netip.MustParseAddr(ip)) {
+			t.Errorf("expected %s to be allowed", ip)
+		}
+	}
+
+}
+
+func TestClientBlocksLoopback(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	_, err := Client(Options{Timeout: time.Second}).Get(server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected ErrBlockedAddress, got %v", err)
+	}
+
+}
+
+func TestClientRedirectLimit(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := Client(Options{
+		Timeout:      time.Second,
+		MaxRedirects: 2,
+		Allow:        func(netip.Addr) bool { return true },
+	})
+
+	if _, err := client.Get(server.URL + "/loop"); err == nil {
+		t.Errorf("expected redirect loop to be stopped")
+	}
+
+}
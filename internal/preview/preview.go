@@ -0,0 +1,197 @@
+// Package preview extracts links from chirps and reads the OpenGraph and
+// Twitter card metadata of the pages they point to.
+package preview
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	MaxURLLength     = 2048
+	MaxFieldLength   = 300
+	DefaultMaxBytes  = 512 << 10
+	defaultUserAgent = "ChirpyBot/1.0 (+link previews)"
+)
+
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+type Fetcher struct {
+	Client   *http.Client
+	MaxBytes int64
+}
+
+var (
+	urlPattern  = regexp.MustCompile(`https?://[^\s<>"']+`)
+	tagPattern  = regexp.MustCompile(`(?is)<(meta|title|/head|body)\b([^>]*)>`)
+	attrPattern = regexp.MustCompile(`(?is)([a-z_:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// ExtractURLs returns the http(s) links in body in order of appearance,
+// without the punctuation that usually follows a link in text.
+func ExtractURLs(body string) []string {
+
+	urls := []string{}
+	seen := map[string]bool{}
+
+	for _, match := range urlPattern.FindAllString(body, -1) {
+		match = strings.TrimRight(match, ".,;:!?)]}")
+
+		parsed, err := url.Parse(match)
+		if err != nil || parsed.Host == "" || len(match) > MaxURLLength || seen[match] {
+			continue
+		}
+
+		seen[match] = true
+		urls = append(urls, match)
+	}
+
+	return urls
+
+}
+
+// Fetch downloads an HTML page and reads its card metadata. Only the first
+// MaxBytes of the page are read.
+func (f *Fetcher) Fetch(ctx context.Context, target string) (Preview, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return Preview{}, fmt.Errorf("unsupported scheme %q", req.URL.Scheme)
+	}
+
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+
+	maxBytes := f.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	if err != nil {
+		return Preview{}, err
+	}
+
+	preview := Parse(string(page), resp.Request.URL)
+	preview.URL = target
+
+	if preview.Title == "" && preview.Description == "" {
+		return Preview{}, fmt.Errorf("page has no title or description")
+	}
+
+	return preview, nil
+
+}
+
+// Parse reads the metadata from the head of an HTML document. OpenGraph
+// values win over Twitter card values, which win over <title> and the plain
+// description. Relative image URLs are resolved against base.
+func Parse(page string, base *url.URL) Preview {
+
+	meta := map[string]string{}
+	title := ""
+
+	for _, tag := range tagPattern.FindAllStringSubmatchIndex(page, -1) {
+		name := strings.ToLower(page[tag[2]:tag[3]])
+
+		if name == "/head" || name == "body" {
+			break
+		}
+
+		if name == "title" {
+			if end := strings.Index(strings.ToLower(page[tag[1]:]), "</title"); end != -1 && title == "" {
+				title = page[tag[1] : tag[1]+end]
+			}
+			continue
+		}
+
+		attrs := map[string]string{}
+		for _, attr := range attrPattern.FindAllStringSubmatch(page[tag[4]:tag[5]], -1) {
+			attrs[strings.ToLower(attr[1])] = attr[2] + attr[3] + attr[4]
+		}
+
+		key := strings.ToLower(attrs["property"])
+		if key == "" {
+			key = strings.ToLower(attrs["name"])
+		}
+
+		if _, exists := meta[key]; key != "" && !exists {
+			meta[key] = attrs["content"]
+		}
+	}
+
+	first := func(values ...string) string {
+		for _, value := range values {
+			if value = clean(value); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	preview := Preview{
+		Title:       first(meta["og:title"], meta["twitter:title"], title),
+		Description: first(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    first(meta["og:site_name"]),
+	}
+
+	if image := first(meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"]); image != "" {
+		if ref, err := url.Parse(image); err == nil && base != nil {
+			resolved := base.ResolveReference(ref)
+			if (resolved.Scheme == "http" || resolved.Scheme == "https") && len(resolved.String()) <= MaxURLLength {
+				preview.ImageURL = resolved.String()
+			}
+		}
+	}
+
+	return preview
+
+}
+
+func clean(value string) string {
+
+	value = strings.Join(strings.Fields(html.UnescapeString(value)), " ")
+
+	if len(value) > MaxFieldLength {
+		cut := MaxFieldLength
+		for cut > 0 && value[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		value = strings.TrimSpace(value[:cut]) + "…"
+	}
+
+	return value
+
+}
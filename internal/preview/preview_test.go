@@ -0,0 +1,98 @@
+package preview
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebasukodo/chirpy/internal/netguard"
+)
+
+const page = `<!doctype html>
+<html><head>
+<title>Fallback title</title>
+<meta name="description" content="plain description">
+<meta property="og:title" content="Chirpy &amp; friends">
+<meta content="/images/card.png" property='og:image'>
+<meta name="twitter:description" content="card description">
+</head><body><meta property="og:site_name" content="ignored"></body></html>`
+
+func TestExtractURLs(t *testing.T) {
+
+	got := ExtractURLs("see https://example.com/a?b=1, and (http://example.org/x). https://example.com/a?b=1 ftp://nope")
+	want := []string{"https://example.com/a?b=1", "http://example.org/x"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+}
+
+func TestFetch(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/post", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final/post", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/final/post", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head>" + strings.Repeat(" ", 4096) + "<title>too late</title>"))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := &Fetcher{
+		Client: netguard.Client(netguard.Options{
+			Timeout:      100 * time.Millisecond,
+			MaxRedirects: 3,
+			Allow:        func(netip.Addr) bool { return true },
+		}),
+		MaxBytes: 1024,
+	}
+
+	preview, err := fetcher.Fetch(context.Background(), server.URL+"/post")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	want := Preview{
+		URL:         server.URL + "/post",
+		Title:       "Chirpy & friends",
+		Description: "card description",
+		ImageURL:    server.URL + "/images/card.png",
+	}
+	if preview != want {
+		t.Errorf("expected %+v, got %+v", want, preview)
+	}
+
+	for _, path := range []string{"/big", "/image", "/slow", "/missing"} {
+		if _, err := fetcher.Fetch(context.Background(), server.URL+path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+
+	guarded := &Fetcher{Client: netguard.Client(netguard.Options{Timeout: time.Second})}
+	if _, err := guarded.Fetch(context.Background(), server.URL+"/final/post"); err == nil {
+		t.Errorf("expected the default client to refuse a loopback server")
+	}
+
+}
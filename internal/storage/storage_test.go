@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3 compatible service.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	t       *testing.T
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		f.t.Errorf("missing or malformed Authorization header: %q", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		f.t.Errorf("payload hash does not match body")
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+}
+
+func TestStorageBackends(t *testing.T) {
+
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+
+	fake := &fakeS3{objects: map[string][]byte{}, t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	backends := map[string]Storage{
+		"local": local,
+		"s3": &S3{
+			Endpoint:  server.URL,
+			Bucket:    "chirpy",
+			Region:    "us-east-1",
+			AccessKey: "key",
+			SecretKey: "secret",
+			Client:    server.Client(),
+		},
+	}
+
+	ctx := context.Background()
+
+	for name, store := range backends {
+		if err := store.Put(ctx, "media/a b.png", []byte("image"), "image/png"); err != nil {
+			t.Fatalf("%s: Put failed: %v", name, err)
+		}
+
+		rc, err := store.Get(ctx, "media/a b.png")
+		if err != nil {
+			t.Fatalf("%s: Get failed: %v", name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+
+		if string(data) != "image" {
+			t.Errorf("%s: expected stored data, got %q", name, data)
+		}
+
+		if err := store.Delete(ctx, "media/a b.png"); err != nil {
+			t.Errorf("%s: Delete failed: %v", name, err)
+		}
+
+		if _, err := store.Get(ctx, "media/a b.png"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound after delete, got %v", name, err)
+		}
+
+		if err := store.Delete(ctx, "media/missing"); err != nil {
+			t.Errorf("%s: deleting a missing object should succeed, got %v", name, err)
+		}
+	}
+
+	if fake.objects["/chirpy/media/a b.png"] != nil {
+		t.Errorf("object was not removed from the bucket")
+	}
+
+}
+
+func TestLocalRejectsTraversal(t *testing.T) {
+
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+
+	for _, key := range []string{"../escape", "/etc/passwd", "media/../../x", "media//x", ""} {
+		if err := local.Put(context.Background(), key, []byte("x"), "text/plain"); err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+
+}
@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/sebasukodo/chirpy/internal/events"
+)
+
+// Hub fans events out to the open streams of this process. Publishing never
+// blocks: a subscriber whose buffer is full is dropped and has to reconnect.
+type Hub struct {
+	mu          sync.Mutex
+	buffer      int
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	hub     *Hub
+	events  chan events.Event
+	dropped chan struct{}
+}
+
+func NewHub(buffer int) *Hub {
+	return &Hub{
+		buffer:      buffer,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+func (h *Hub) Subscribe() *Subscription {
+
+	sub := &Subscription{
+		hub:     h,
+		events:  make(chan events.Event, h.buffer),
+		dropped: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+
+}
+
+func (h *Hub) Publish(event events.Event) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.dropped)
+		}
+	}
+
+}
+
+// Len returns the number of open subscriptions.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscribers)
+}
+
+func (s *Subscription) Events() <-chan events.Event {
+	return s.events
+}
+
+// Dropped is closed when the subscriber fell too far behind.
+func (s *Subscription) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	delete(s.hub.subscribers, s)
+}
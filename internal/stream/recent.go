@@ -0,0 +1,46 @@
+package stream
+
+import "github.com/google/uuid"
+
+// Recent remembers the last ids it was given, so a stream can tell which
+// chirps it sent without keeping every id of a long running connection.
+type Recent struct {
+	ids  []uuid.UUID
+	next int
+	set  map[uuid.UUID]struct{}
+}
+
+func NewRecent(size int) *Recent {
+	return &Recent{
+		ids: make([]uuid.UUID, 0, size),
+		set: make(map[uuid.UUID]struct{}, size),
+	}
+}
+
+// Add records id and forgets the oldest one once the limit is reached.
+func (r *Recent) Add(id uuid.UUID) {
+
+	if _, ok := r.set[id]; ok {
+		return
+	}
+
+	if len(r.ids) < cap(r.ids) {
+		r.ids = append(r.ids, id)
+	} else {
+		delete(r.set, r.ids[r.next])
+		r.ids[r.next] = id
+		r.next = (r.next + 1) % len(r.ids)
+	}
+
+	r.set[id] = struct{}{}
+
+}
+
+func (r *Recent) Contains(id uuid.UUID) bool {
+	_, ok := r.set[id]
+	return ok
+}
+
+func (r *Recent) Remove(id uuid.UUID) {
+	delete(r.set, id)
+}
@@ -0,0 +1,76 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Writer writes Server-Sent Events and flushes after each one.
+type Writer struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// NewWriter sends the event stream headers. It fails when the response
+// cannot be flushed, since events would then sit in a buffer.
+func NewWriter(w http.ResponseWriter, retry time.Duration) (*Writer, error) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sse := &Writer{w: w, flusher: flusher}
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", retry.Milliseconds()); err != nil {
+		return nil, err
+	}
+	flusher.Flush()
+
+	return sse, nil
+
+}
+
+// Event writes one event. An empty id leaves the client's last event id as
+// it is, so only events that can be resumed from should carry one.
+func (s *Writer) Event(id string, name string, data []byte) error {
+
+	buf := bytes.Buffer{}
+	if id != "" {
+		fmt.Fprintf(&buf, "id: %s\n", id)
+	}
+	if name != "" {
+		fmt.Fprintf(&buf, "event: %s\n", name)
+	}
+	for _, line := range bytes.Split(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), []byte("\n")) {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteByte('\n')
+
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+
+}
+
+// Comment writes a line clients ignore, which keeps idle connections open
+// through proxies.
+func (s *Writer) Comment(text string) error {
+
+	if _, err := fmt.Fprintf(s.w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+
+}
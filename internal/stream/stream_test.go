@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sebasukodo/chirpy/internal/events"
+)
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+
+	hub := NewHub(2)
+
+	fast := hub.Subscribe()
+	slow := hub.Subscribe()
+
+	for i := 0; i < 2; i++ {
+		hub.Publish(events.Event{Type: events.TypeChirpCreated, ChirpID: uuid.New()})
+		<-fast.Events()
+	}
+
+	select {
+	case <-slow.Dropped():
+		t.Fatal("subscriber dropped before its buffer was full")
+	default:
+	}
+
+	hub.Publish(events.Event{Type: events.TypeChirpCreated, ChirpID: uuid.New()})
+
+	select {
+	case <-slow.Dropped():
+	default:
+		t.Fatal("expected full subscriber to be dropped")
+	}
+
+	if hub.Len() != 1 {
+		t.Errorf("expected 1 subscriber left, got %d", hub.Len())
+	}
+
+	fast.Close()
+	if hub.Len() != 0 {
+		t.Errorf("expected no subscribers after close, got %d", hub.Len())
+	}
+
+}
+
+func TestWriterFormatsEvents(t *testing.T) {
+
+	rec := httptest.NewRecorder()
+
+	sse, err := NewWriter(rec, 3*time.Second)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	if err := sse.Event("42", "chirp", []byte("first\nsecond")); err != nil {
+		t.Fatalf("Event failed: %v", err)
+	}
+
+	if err := sse.Comment("heartbeat"); err != nil {
+		t.Fatalf("Comment failed: %v", err)
+	}
+
+	expected := "retry: 3000\n\nid: 42\nevent: chirp\ndata: first\ndata: second\n\n: heartbeat\n\n"
+	if rec.Body.String() != expected {
+		t.Errorf("unexpected stream:\n%q\nexpected:\n%q", rec.Body.String(), expected)
+	}
+
+	if rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+
+}
+
+func TestRecentForgetsOldest(t *testing.T) {
+
+	recent := NewRecent(2)
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+
+	recent.Add(first)
+	recent.Add(second)
+	recent.Add(second)
+
+	if !recent.Contains(first) || !recent.Contains(second) {
+		t.Fatalf("expected both ids within the limit")
+	}
+
+	recent.Add(third)
+
+	if recent.Contains(first) {
+		t.Errorf("expected the oldest id to be forgotten")
+	}
+	if !recent.Contains(second) || !recent.Contains(third) {
+		t.Errorf("expected the newest ids to be kept")
+	}
+
+	recent.Remove(third)
+	if recent.Contains(third) {
+		t.Errorf("expected a removed id to be gone")
+	}
+
+}
@@ -0,0 +1,110 @@
+// Package visibility decides who may see a chirp. Handlers load the facts
+// about the viewer and call CanView or CanList, nothing else should compare
+// visibility levels.
+package visibility
+
+import "strings"
+
+const (
+	Public    = "public"
+	Followers = "followers"
+	Unlisted  = "unlisted"
+	Direct    = "direct"
+)
+
+// Relationship describes how the viewer relates to a chirp. The zero value is
+// an anonymous viewer. Blocked is set when either side blocked the other,
+// Muted when the viewer muted the author or a keyword of the chirp.
+type Relationship struct {
+	IsAuthor  bool
+	Follows   bool
+	Mentioned bool
+	Blocked   bool
+	Muted     bool
+}
+
+func Valid(level string) bool {
+	switch level {
+	case Public, Followers, Unlisted, Direct:
+		return true
+	}
+	return false
+}
+
+// CanView reports whether the viewer may open the chirp directly, by id or link.
+func CanView(level string, rel Relationship) bool {
+
+	if rel.IsAuthor {
+		return true
+	}
+
+	if rel.Blocked {
+		return false
+	}
+
+	switch level {
+	case Public, Unlisted:
+		return true
+	case Followers:
+		return rel.Follows || rel.Mentioned
+	case Direct:
+		return rel.Mentioned
+	}
+
+	return false
+
+}
+
+// CanList reports whether the chirp shows up in listings, feeds and search.
+// Unlisted and direct chirps only show up for their author. Muted chirps can
+// still be opened by link but are left out of listings.
+func CanList(level string, rel Relationship) bool {
+
+	if !CanView(level, rel) {
+		return false
+	}
+
+	if rel.IsAuthor {
+		return true
+	}
+
+	return !rel.Muted && (level == Public || level == Followers)
+
+}
+
+// ContainsKeyword reports whether body contains one of the muted keywords,
+// ignoring case. Keywords only match whole words or phrases.
+func ContainsKeyword(body string, keywords []string) bool {
+
+	body = strings.ToLower(body)
+
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(keyword)
+		if keyword == "" {
+			continue
+		}
+
+		for offset := 0; ; {
+			i := strings.Index(body[offset:], keyword)
+			if i == -1 {
+				break
+			}
+			start, end := offset+i, offset+i+len(keyword)
+			if isBoundary(body, start-1) && isBoundary(body, end) {
+				return true
+			}
+			offset = start + 1
+		}
+	}
+
+	return false
+
+}
+
+func isBoundary(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	c := s[i]
+	return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c >= 0x80)
+}
@@ -0,0 +1,79 @@
+package visibility
+
+import "testing"
+
+func TestPolicy(t *testing.T) {
+
+	anonymous := Relationship{}
+	author := Relationship{IsAuthor: true}
+	follower := Relationship{Follows: true}
+	mentioned := Relationship{Mentioned: true}
+	blocked := Relationship{Follows: true, Mentioned: true, Blocked: true}
+	muted := Relationship{Follows: true, Muted: true}
+
+	type testCase struct {
+		name    string
+		level   string
+		rel     Relationship
+		canView bool
+		canList bool
+	}
+
+	runCases := []testCase{
+		{name: "public anonymous", level: Public, rel: anonymous, canView: true, canList: true},
+		{name: "public follower", level: Public, rel: follower, canView: true, canList: true},
+		{name: "followers anonymous", level: Followers, rel: anonymous},
+		{name: "followers follower", level: Followers, rel: follower, canView: true, canList: true},
+		{name: "followers mentioned", level: Followers, rel: mentioned, canView: true, canList: true},
+		{name: "followers author", level: Followers, rel: author, canView: true, canList: true},
+		{name: "unlisted anonymous", level: Unlisted, rel: anonymous, canView: true},
+		{name: "unlisted follower", level: Unlisted, rel: follower, canView: true},
+		{name: "unlisted author", level: Unlisted, rel: author, canView: true, canList: true},
+		{name: "direct anonymous", level: Direct, rel: anonymous},
+		{name: "direct follower", level: Direct, rel: follower},
+		{name: "direct mentioned", level: Direct, rel: mentioned, canView: true},
+		{name: "direct author", level: Direct, rel: author, canView: true, canList: true},
+		{name: "unknown level", level: "secret", rel: follower},
+		{name: "public blocked", level: Public, rel: blocked},
+		{name: "direct blocked", level: Direct, rel: blocked},
+		{name: "public blocked author", level: Public, rel: Relationship{IsAuthor: true, Blocked: true}, canView: true, canList: true},
+		{name: "public muted", level: Public, rel: muted, canView: true},
+		{name: "followers muted", level: Followers, rel: muted, canView: true},
+	}
+
+	for _, test := range runCases {
+		if got := CanView(test.level, test.rel); got != test.canView {
+			t.Errorf("%s: CanView = %v, expected %v", test.name, got, test.canView)
+		}
+		if got := CanList(test.level, test.rel); got != test.canList {
+			t.Errorf("%s: CanList = %v, expected %v", test.name, got, test.canList)
+		}
+	}
+
+}
+
+func TestContainsKeyword(t *testing.T) {
+
+	type testCase struct {
+		body     string
+		keywords []string
+		expected bool
+	}
+
+	runCases := []testCase{
+		{body: "Spoilers for the finale", keywords: []string{"spoilers"}, expected: true},
+		{body: "the Finale was great", keywords: []string{"finale"}, expected: true},
+		{body: "no spoilerstuff here", keywords: []string{"spoiler"}},
+		{body: "big game tonight", keywords: []string{"game tonight"}, expected: true},
+		{body: "nothing muted", keywords: []string{"", "other"}},
+		{body: "endgame", keywords: []string{"game"}},
+		{body: "game, set", keywords: []string{"game"}, expected: true},
+	}
+
+	for _, test := range runCases {
+		if got := ContainsKeyword(test.body, test.keywords); got != test.expected {
+			t.Errorf("ContainsKeyword(%q, %q) = %v, expected %v", test.body, test.keywords, got, test.expected)
+		}
+	}
+
+}
@@ -0,0 +1,95 @@
+// Package webhook signs and verifies webhook payloads. A signature header
+// looks like "t=1700000000,v1=<hex>" where the hex value is the HMAC-SHA256
+// of "<t>.<body>". Several v1 values may be sent while secrets are rotated.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMalformed = errors.New("malformed signature header")
+	ErrExpired   = errors.New("signature timestamp outside tolerance")
+	ErrMismatch  = errors.New("no matching signature")
+)
+
+// Sign returns the header value for body signed at t with every secret.
+func Sign(secrets []string, t time.Time, body []byte) string {
+
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+
+	parts := []string{"t=" + timestamp}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+hex.EncodeToString(mac(secret, timestamp, body)))
+	}
+
+	return strings.Join(parts, ",")
+
+}
+
+// Verify checks that header carries a signature of body by any of the
+// secrets, made no further than tolerance from now.
+func Verify(header string, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+
+	timestamp := ""
+	signatures := [][]byte{}
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformed
+		}
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				return ErrMalformed
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrMalformed
+	}
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: %v", ErrExpired, age.Round(time.Second))
+	}
+
+	for _, secret := range secrets {
+		expected := mac(secret, timestamp, body)
+		for _, signature := range signatures {
+			if hmac.Equal(expected, signature) {
+				return nil
+			}
+		}
+	}
+
+	return ErrMismatch
+
+}
+
+func mac(secret string, timestamp string, body []byte) []byte {
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+
+	return h.Sum(nil)
+
+}
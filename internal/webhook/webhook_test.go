@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded"}`)
+
+	type testCase struct {
+		name    string
+		header  string
+		body    []byte
+		secrets []string
+		err     error
+	}
+
+	runCases := []testCase{
+		{name: "valid", header: Sign([]string{"old"}, now, body), body: body, secrets: []string{"new", "old"}},
+		{name: "rotated", header: Sign([]string{"old", "new"}, now, body), body: body, secrets: []string{"new"}},
+		{name: "tampered", header: Sign([]string{"old"}, now, body), body: []byte(`{"event":"user.refunded"}`), secrets: []string{"old"}, err: ErrMismatch},
+		{name: "wrong secret", header: Sign([]string{"other"}, now, body), body: body, secrets: []string{"old"}, err: ErrMismatch},
+		{name: "too old", header: Sign([]string{"old"}, now.Add(-time.Hour), body), body: body, secrets: []string{"old"}, err: ErrExpired},
+		{name: "from the future", header: Sign([]string{"old"}, now.Add(time.Hour), body), body: body, secrets: []string{"old"}, err: ErrExpired},
+		{name: "no signature", header: "t=1700000000", body: body, secrets: []string{"old"}, err: ErrMalformed},
+		{name: "garbage", header: "ApiKey f271c81ff7084ee5b99a5091b42d486e", body: body, secrets: []string{"old"}, err: ErrMalformed},
+	}
+
+	for _, test := range runCases {
+		err := Verify(test.header, test.body, test.secrets, DefaultTolerance, now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+}
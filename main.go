@@ -7,12 +7,25 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/sebasukodo/chirpy/internal/auth"
+	"github.com/sebasukodo/chirpy/internal/billing"
 	"github.com/sebasukodo/chirpy/internal/database"
+	"github.com/sebasukodo/chirpy/internal/entitlements"
+	"github.com/sebasukodo/chirpy/internal/events"
 	"github.com/sebasukodo/chirpy/internal/handler"
+	"github.com/sebasukodo/chirpy/internal/jobs"
+	"github.com/sebasukodo/chirpy/internal/netguard"
+	"github.com/sebasukodo/chirpy/internal/preview"
+	"github.com/sebasukodo/chirpy/internal/storage"
+	"github.com/sebasukodo/chirpy/internal/stream"
 )
 
 const port = "8080"
@@ -32,20 +45,133 @@ func main() {
 		log.Fatalf("could not connect to database: %v", err)
 	}
 
+	passwordPolicy := auth.PasswordPolicy{
+		MinLength:   envInt("PASSWORD_MIN_LENGTH", auth.DefaultPasswordMinLength),
+		MinStrength: envInt("PASSWORD_MIN_STRENGTH", auth.DefaultPasswordMinStrength),
+	}
+
+	if corpusPath := os.Getenv("BREACHED_PASSWORDS_FILE"); corpusPath != "" {
+		passwordPolicy.Breached, err = auth.LoadBreachedPasswords(corpusPath)
+		if err != nil {
+			log.Fatalf("could not load breached passwords: %v", err)
+		}
+		log.Printf("loaded %d breached password hashes", passwordPolicy.Breached.Len())
+	}
+
+	hashParams := &argon2id.Params{
+		Memory:      uint32(envInt("ARGON2_MEMORY_KIB", int(argon2id.DefaultParams.Memory))),
+		Iterations:  uint32(envInt("ARGON2_ITERATIONS", int(argon2id.DefaultParams.Iterations))),
+		Parallelism: uint8(envInt("ARGON2_PARALLELISM", int(argon2id.DefaultParams.Parallelism))),
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	}
+
+	var blobStorage storage.Storage
+	switch backend := envString("STORAGE_BACKEND", "local"); backend {
+	case "local":
+		blobStorage, err = storage.NewLocal(envString("MEDIA_DIR", "./data/media"))
+		if err != nil {
+			log.Fatalf("could not create media directory: %v", err)
+		}
+	case "s3":
+		blobStorage = &storage.S3{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    envString("S3_REGION", "us-east-1"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}
+	default:
+		log.Fatalf("unknown STORAGE_BACKEND %q, use local or s3", backend)
+	}
+
+	plans := entitlements.Default()
+
+	free, red := plans[entitlements.PlanFree], plans[entitlements.PlanRed]
+	free.EditWindow = time.Duration(envInt("CHIRP_EDIT_WINDOW_MINUTES", 15)) * time.Minute
+	red.EditWindow = time.Duration(envInt("CHIRP_EDIT_WINDOW_RED_MINUTES", 60)) * time.Minute
+	plans[entitlements.PlanFree], plans[entitlements.PlanRed] = free, red
+
+	polka := &billing.Polka{
+		APIKey:   os.Getenv("POLKA_KEY"),
+		Secrets:  envList("POLKA_WEBHOOK_SECRETS"),
+		Checkout: os.Getenv("POLKA_CHECKOUT_URL"),
+	}
+	billingProviders := map[string]billing.Provider{polka.Name(): polka}
+
+	// The fake provider accepts unsigned webhooks, so it only exists in dev.
+	if os.Getenv("PLATFORM") == "dev" {
+		fake := &billing.Fake{CheckoutBase: "/billing/fake/checkout"}
+		billingProviders[fake.Name()] = fake
+	}
+
+	checkoutProvider := envString("BILLING_CHECKOUT_PROVIDER", "polka")
+	if _, ok := billingProviders[checkoutProvider]; !ok {
+		log.Fatalf("unknown BILLING_CHECKOUT_PROVIDER %q", checkoutProvider)
+	}
+
 	apiCfg := &handler.ApiConfig{
 		FileserverHits: atomic.Int32{},
+		DB:             db,
 		DbQueries:      database.New(db),
 		Platform:       os.Getenv("PLATFORM"),
 		TokenSecret:    os.Getenv("TOKENSECRET"),
-		PolkaApiKey:    os.Getenv("POLKA_KEY"),
+		AdminApiKey:    os.Getenv("ADMIN_KEY"),
+		PasswordPolicy: passwordPolicy,
+		HashParams:     hashParams,
+
+		BillingProviders: billingProviders,
+		CheckoutProvider: checkoutProvider,
+
+		AccountGracePeriod: time.Duration(envInt("ACCOUNT_DELETION_GRACE_DAYS", 30)) * 24 * time.Hour,
+		ExportDir:          envString("EXPORT_DIR", "./data/exports"),
+		ExportTTL:          time.Duration(envInt("EXPORT_TTL_HOURS", 48)) * time.Hour,
+		Plans:              plans,
+		Storage:            blobStorage,
+		MediaMaxBytes:      int64(envInt("MEDIA_MAX_MB", 5)) << 20,
+		MediaOrphanTTL:     time.Duration(envInt("MEDIA_ORPHAN_HOURS", 24)) * time.Hour,
+		PreviewFetcher: &preview.Fetcher{
+			Client: netguard.Client(netguard.Options{
+				Timeout:      5 * time.Second,
+				MaxRedirects: 3,
+			}),
+			MaxBytes: preview.DefaultMaxBytes,
+		},
+		WebhookClient: netguard.Client(netguard.Options{
+			Timeout: handler.WebhookTimeout,
+		}),
+		ChirpHub: stream.NewHub(handler.StreamBufferSize),
+	}
+
+	switch backend := envString("EVENT_BUS", "memory"); backend {
+	case "memory":
+		apiCfg.Events = events.NewMemory()
+	case "postgres":
+		apiCfg.Events, err = events.NewPostgres(db, dbURL, "chirpy_events")
+		if err != nil {
+			log.Fatalf("could not listen for events: %v", err)
+		}
+	default:
+		log.Fatalf("unknown EVENT_BUS %q, use memory or postgres", backend)
 	}
 
-	if err := apiCfg.DbQueries.RevokeAllExpiredSessionIDs(context.Background()); err != nil {
-		log.Printf("startup session id cleanup failed: %v", err)
+	apiCfg.SubscribeNotifications(apiCfg.Events)
+	apiCfg.SubscribeStreams(apiCfg.Events)
+	apiCfg.SubscribeWebhooks(apiCfg.Events)
+
+	apiCfg.Jobs = jobs.New(apiCfg.DbQueries)
+	apiCfg.RegisterJobs()
+
+	if len(os.Args) > 1 {
+		if err := runCommand(apiCfg, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	if err := apiCfg.DbQueries.RevokeAllExpiredRefreshToken(context.Background()); err != nil {
-		log.Printf("startup refresh token cleanup failed: %v", err)
+	// With JOB_WORKERS=0 jobs are left to separate `chirpy worker` processes.
+	if workers := envInt("JOB_WORKERS", 4); workers > 0 {
+		go apiCfg.Jobs.Run(context.Background(), workers)
 	}
 
 	mux := http.NewServeMux()
@@ -55,12 +181,32 @@ func main() {
 	mux.Handle("/static/", fileServerHandler)
 
 	mux.Handle("/profile", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.ProfilePage)))
+	mux.Handle("GET /u/{handle}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.UserProfilePage)))
+	mux.Handle("GET /timeline", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.TimelinePage)))
+	mux.Handle("GET /chirps/{chirpID}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.ChirpPage)))
+	mux.Handle("GET /media/{mediaID}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.MediaGet)))
+	mux.Handle("GET /media/{mediaID}/thumbnail", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.MediaGetThumbnail)))
+
+	mux.Handle("GET /inbox", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.InboxPage)))
+	mux.Handle("GET /inbox/{conversationID}", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.ConversationPage)))
+
+	mux.Handle("GET /bookmarks", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.BookmarksPage)))
+	mux.Handle("GET /lists", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.ListsPage)))
+	mux.Handle("GET /lists/{listID}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.ListPage)))
+
+	mux.Handle("GET /notifications", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.NotificationsPage)))
+	mux.Handle("GET /notifications/bell", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.NotificationBell)))
 
 	mux.HandleFunc("GET /healthz", handler.Readiness)
 
 	mux.HandleFunc("POST /api/register", apiCfg.UsersRegisterForm)
 	mux.HandleFunc("POST /api/login", apiCfg.UsersLoginForm)
 	mux.Handle("DELETE /api/users/me", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.UsersDelete)))
+	mux.Handle("POST /api/users/me/export", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersExportCreate)))
+	mux.Handle("GET /api/users/me/exports", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersExportList)))
+	mux.Handle("GET /api/users/me/exports/{exportID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersExportDownload)))
+	mux.Handle("POST /api/users/me/import", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersImportCreate)))
+	mux.Handle("GET /api/users/me/imports/{importID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersImportGet)))
 
 	mux.Handle("GET /register", apiCfg.MiddlewareCheckAuthLoginPage(http.HandlerFunc(apiCfg.Register)))
 	mux.Handle("GET /login", apiCfg.MiddlewareCheckAuthLoginPage(http.HandlerFunc(apiCfg.Login)))
@@ -69,12 +215,90 @@ func main() {
 	mux.HandleFunc("POST /logout", apiCfg.UserLogout)
 
 	mux.HandleFunc("POST /api/chirps", apiCfg.ChirpsCreate)
-	mux.HandleFunc("GET /api/chirps", apiCfg.ChirpsGetAll)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.ChirpsGetByID)
+	mux.Handle("GET /api/chirps", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.ChirpsGetAll)))
+	mux.Handle("GET /api/chirps/{chirpID}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.ChirpsGetByID)))
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.ChirpsDeleteByID)
+	mux.Handle("PATCH /api/chirps/{chirpID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ChirpsUpdate)))
+	mux.Handle("GET /api/chirps/{chirpID}/history", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.ChirpsHistory)))
+	mux.Handle("POST /api/chirps/{chirpID}/poll/votes", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ChirpsPollVote)))
+
+	mux.Handle("POST /api/webhooks", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.WebhooksCreate)))
+	mux.Handle("GET /api/webhooks", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.WebhooksList)))
+	mux.Handle("PUT /api/webhooks/{webhookID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.WebhooksUpdate)))
+	mux.Handle("DELETE /api/webhooks/{webhookID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.WebhooksDelete)))
+	mux.Handle("POST /api/webhooks/{webhookID}/enable", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.WebhooksEnable)))
+	mux.Handle("POST /api/webhooks/{webhookID}/test", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.WebhooksTest)))
+	mux.Handle("GET /api/webhooks/{webhookID}/deliveries", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.WebhooksDeliveries)))
+
+	mux.Handle("POST /api/chirps/{chirpID}/pin", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ChirpsPin)))
+	mux.Handle("DELETE /api/chirps/{chirpID}/pin", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ChirpsUnpin)))
+
+	mux.Handle("POST /api/chirps/{chirpID}/bookmark", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ChirpsBookmark)))
+	mux.Handle("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ChirpsUnbookmark)))
+	mux.Handle("GET /api/bookmarks", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.BookmarksList)))
+
+	mux.Handle("POST /api/lists", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ListsCreate)))
+	mux.Handle("GET /api/lists", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ListsList)))
+	mux.Handle("GET /api/lists/{listID}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.ListsGet)))
+	mux.Handle("PUT /api/lists/{listID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ListsUpdate)))
+	mux.Handle("DELETE /api/lists/{listID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ListsDelete)))
+	mux.Handle("POST /api/lists/{listID}/members", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ListMembersAdd)))
+	mux.Handle("DELETE /api/lists/{listID}/members/{userID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ListMembersRemove)))
+	mux.Handle("GET /api/lists/{listID}/chirps", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.ListChirps)))
+
+	mux.Handle("GET /api/stream/public", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.StreamPublic)))
+	mux.Handle("GET /api/stream/feed", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.StreamFeed)))
+	mux.Handle("GET /api/stream/user/{userID}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.StreamUser)))
+
+	mux.Handle("GET /api/feed", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ChirpsFeed)))
+	mux.Handle("GET /api/users/{handle}", apiCfg.MiddlewareOptionalAuth(http.HandlerFunc(apiCfg.UsersGetProfile)))
+	mux.Handle("PUT /api/users/me/profile", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersUpdateProfile)))
+
+	mux.Handle("POST /api/users/{userID}/follow", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersFollow)))
+	mux.Handle("DELETE /api/users/{userID}/follow", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersUnfollow)))
+
+	mux.Handle("POST /api/users/{userID}/block", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersBlock)))
+	mux.Handle("DELETE /api/users/{userID}/block", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersUnblock)))
+
+	mux.Handle("POST /api/users/{userID}/mute", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersMute)))
+	mux.Handle("DELETE /api/users/{userID}/mute", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.UsersUnmute)))
+	mux.Handle("GET /api/mutes", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.MutesList)))
+	mux.Handle("POST /api/mutes/keywords", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.KeywordMutesCreate)))
+	mux.Handle("DELETE /api/mutes/keywords/{muteID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.KeywordMutesDelete)))
+
+	mux.Handle("POST /api/conversations", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ConversationsCreate)))
+	mux.Handle("GET /api/conversations", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ConversationsList)))
+	mux.Handle("GET /api/conversations/unread", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ConversationsUnreadCount)))
+	mux.Handle("POST /api/conversations/{conversationID}/read", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.ConversationsMarkRead)))
+	mux.Handle("GET /api/conversations/{conversationID}/messages", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.MessagesList)))
+	mux.Handle("POST /api/conversations/{conversationID}/messages", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.MessagesCreate)))
+
+	mux.Handle("GET /api/notifications", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.NotificationsList)))
+	mux.Handle("GET /api/notifications/unread", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.NotificationsUnreadCount)))
+	mux.Handle("POST /api/notifications/read", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.NotificationsMarkRead)))
+	mux.Handle("GET /api/notifications/preferences", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.NotificationPreferencesGet)))
+	mux.Handle("PUT /api/notifications/preferences", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.NotificationPreferencesUpdate)))
+
+	mux.Handle("POST /api/drafts", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.DraftsCreate)))
+	mux.Handle("GET /api/drafts", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.DraftsList)))
+	mux.Handle("GET /api/drafts/{draftID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.DraftsGet)))
+	mux.Handle("PUT /api/drafts/{draftID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.DraftsUpdate)))
+	mux.Handle("DELETE /api/drafts/{draftID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.DraftsDelete)))
+
+	mux.Handle("POST /api/media", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.MediaUpload)))
+	mux.Handle("PATCH /api/media/{mediaID}", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.MediaUpdate)))
 
 	mux.HandleFunc("POST /admin/reset", apiCfg.Reset)
+	mux.Handle("GET /admin/password-hashes", apiCfg.MiddlewareAdmin(http.HandlerFunc(apiCfg.AdminPasswordHashReport)))
+	mux.Handle("GET /admin/webhooks", apiCfg.MiddlewareAdmin(http.HandlerFunc(apiCfg.AdminWebhookEvents)))
+	mux.Handle("POST /admin/webhooks/{eventID}/replay", apiCfg.MiddlewareAdmin(http.HandlerFunc(apiCfg.AdminWebhookEventReplay)))
+	mux.Handle("GET /admin/jobs", apiCfg.MiddlewareAdmin(http.HandlerFunc(apiCfg.AdminJobs)))
+	mux.Handle("POST /admin/jobs/{jobID}/retry", apiCfg.MiddlewareAdmin(http.HandlerFunc(apiCfg.AdminJobRetry)))
 
+	mux.Handle("POST /api/billing/checkout", apiCfg.MiddlewareAPIAuth(http.HandlerFunc(apiCfg.BillingCheckout)))
+	mux.Handle("GET /billing/checkout", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.BillingCheckoutPage)))
+	mux.Handle("GET /billing/fake/checkout", apiCfg.MiddlewareCheckAuth(http.HandlerFunc(apiCfg.BillingFakeCheckout)))
+	mux.HandleFunc("POST /api/billing/webhooks/{provider}", apiCfg.BillingWebhook)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.VIP)
 
 	server := http.Server{
@@ -86,3 +310,44 @@ func main() {
 	log.Fatal(server.ListenAndServe())
 
 }
+
+// envList reads a comma separated list, ignoring empty entries.
+func envList(name string) []string {
+
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+
+}
+
+func envInt(name string, fallback int) int {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be a number: %v", name, err)
+	}
+
+	return number
+
+}
+
+func envString(name string, fallback string) string {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	return value
+
+}
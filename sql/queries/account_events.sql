@@ -0,0 +1,14 @@
+-- name: CreateAccountEvent :exec
+INSERT INTO account_events(id, created_at, user_id, event, detail)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+);
+
+-- name: GetAccountEventsForUser :many
+SELECT * FROM account_events
+WHERE user_id = $1
+ORDER BY created_at;
\ No newline at end of file
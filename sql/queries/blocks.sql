@@ -0,0 +1,26 @@
+-- name: BlockUser :exec
+INSERT INTO blocks(blocker_id, blocked_id, created_at)
+VALUES(
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: HasBlockBetween :one
+SELECT EXISTS(
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::uuid[]))
+    OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(other_ids)::uuid[]))
+);
+
+-- name: GetBlockedAmong :many
+SELECT blocked_id AS user_id FROM blocks
+WHERE blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::uuid[])
+UNION
+SELECT blocker_id AS user_id FROM blocks
+WHERE blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(other_ids)::uuid[]);
\ No newline at end of file
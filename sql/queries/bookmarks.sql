@@ -0,0 +1,29 @@
+-- name: CreateBookmark :execrows
+INSERT INTO bookmarks(user_id, chirp_id, created_at)
+VALUES(
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: CountBookmarks :one
+SELECT COUNT(*) FROM bookmarks
+WHERE user_id = $1;
+
+-- name: GetBookmark :one
+SELECT * FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarkedChirps :many
+SELECT chirps.* FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE bookmarks.user_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+AND (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_chirp_id)::uuid)
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(page_size);
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- name: CreateChirpMentions :execrows
+INSERT INTO chirp_mentions(chirp_id, user_id)
+SELECT sqlc.arg(chirp_id)::uuid, id FROM users
+WHERE id = ANY(sqlc.arg(user_ids)::uuid[]) AND deleted_at IS NULL
+ON CONFLICT DO NOTHING;
+
+-- name: GetMentionedChirpsAmong :many
+SELECT chirp_id FROM chirp_mentions
+WHERE user_id = sqlc.arg(user_id) AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
\ No newline at end of file
@@ -1,26 +1,52 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, preview_url, visibility)
 VALUES(
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: ImportChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, visibility)
+VALUES(
+    gen_random_uuid(),
+    $3,
+    $3,
+    $1,
+    $2,
+    $4
 )
 RETURNING *;
 
 -- name: GetAllChirps :many
-SELECT * FROM chirps
-ORDER BY created_at ASC;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+ORDER BY chirps.created_at ASC;
 
 -- name: GetAllChirpsFromAuthor :many
-SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.deleted_at IS NULL
+ORDER BY chirps.created_at;
+
+-- name: GetFeedChirps :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+AND (chirps.user_id = $1 OR chirps.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1))
+ORDER BY chirps.created_at DESC
+LIMIT 100;
 
 -- name: GetChirpByID :one
-SELECT * FROM chirps
-WHERE id = $1;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.deleted_at IS NULL;
 
 -- name: GetChirpUserID :one
 SELECT user_id FROM chirps
@@ -28,4 +54,58 @@ WHERE id = $1;
 
 -- name: DeleteChirpByID :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, preview_url = $3, updated_at = NOW(), edited_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: LockChirpByID :one
+SELECT * FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetChirpsAfter :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+AND (chirps.created_at, chirps.id) > (sqlc.arg(created_at)::timestamp, sqlc.arg(id)::uuid)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetChirpsByAuthorPage :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+AND (chirps.created_at, chirps.id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: PinChirp :execrows
+UPDATE chirps
+SET pin_position = (
+    SELECT COALESCE(MAX(pinned.pin_position), 0) + 1 FROM chirps AS pinned
+    WHERE pinned.user_id = $2
+)
+WHERE id = $1 AND user_id = $2 AND pin_position IS NULL;
+
+-- name: UnpinChirp :exec
+UPDATE chirps
+SET pin_position = NULL
+WHERE id = $1 AND user_id = $2;
+
+-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND pin_position IS NOT NULL;
+
+-- name: GetPinnedChirps :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND chirps.pin_position IS NOT NULL AND users.deleted_at IS NULL
+ORDER BY chirps.pin_position DESC;
+
+-- name: CountChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2;
\ No newline at end of file
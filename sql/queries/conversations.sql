@@ -0,0 +1,96 @@
+-- name: CreateConversation :one
+INSERT INTO conversations(id, created_at, updated_at, is_group, direct_key)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members(conversation_id, user_id, joined_at, last_read_at)
+VALUES(
+    $1,
+    $2,
+    NOW(),
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: GetConversationMember :one
+SELECT * FROM conversation_members
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetConversationsForUser :many
+SELECT conversations.*,
+    (SELECT COUNT(*) FROM messages
+    WHERE messages.conversation_id = conversations.id
+    AND messages.created_at > conversation_members.last_read_at
+    AND messages.sender_id IS DISTINCT FROM conversation_members.user_id) AS unread_count,
+    COALESCE((SELECT body FROM messages
+    WHERE messages.conversation_id = conversations.id
+    ORDER BY messages.created_at DESC
+    LIMIT 1), '')::text AS last_message
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.updated_at DESC;
+
+-- name: GetConversationMembers :many
+SELECT conversation_members.conversation_id, users.id AS user_id, users.handle, users.deleted_at FROM conversation_members
+JOIN users ON users.id = conversation_members.user_id
+WHERE conversation_members.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_members.joined_at;
+
+-- name: CountActiveUsers :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY(sqlc.arg(user_ids)::uuid[]) AND deleted_at IS NULL;
+
+-- name: GetUnreadMessageCount :one
+SELECT COUNT(*) FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+WHERE conversation_members.user_id = $1
+AND messages.created_at > conversation_members.last_read_at
+AND messages.sender_id IS DISTINCT FROM conversation_members.user_id;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: CreateMessage :one
+INSERT INTO messages(id, created_at, conversation_id, sender_id, body)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetMessagesPage :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND (created_at, id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetMessageByID :one
+SELECT * FROM messages
+WHERE id = $1;
+
+-- name: DeleteEmptyConversations :execrows
+DELETE FROM conversations
+WHERE NOT EXISTS (
+    SELECT 1 FROM conversation_members
+    WHERE conversation_members.conversation_id = conversations.id
+);
\ No newline at end of file
@@ -0,0 +1,41 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports(id, created_at, updated_at, user_id, expires_at)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetDataExportByID :one
+SELECT * FROM data_exports
+WHERE id = $1;
+
+-- name: GetDataExportsForUser :many
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: CountOpenDataExports :one
+SELECT COUNT(*) FROM data_exports
+WHERE user_id = $1 AND status IN ('pending', 'ready') AND expires_at > NOW();
+
+-- name: SetDataExportReady :exec
+UPDATE data_exports
+SET status = 'ready', file_path = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetDataExportFailed :exec
+UPDATE data_exports
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetExpiredDataExports :many
+SELECT * FROM data_exports
+WHERE expires_at < NOW();
+
+-- name: DeleteDataExportByID :exec
+DELETE FROM data_exports
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,52 @@
+-- name: CreateDraft :one
+INSERT INTO drafts(id, created_at, updated_at, user_id, body, publish_at, status, visibility)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetDraftByID :one
+SELECT * FROM drafts
+WHERE id = $1;
+
+-- name: GetDraftsForUser :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $3, publish_at = $4, status = $5, visibility = $6, error = NULL, updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status <> 'published'
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueDraft :one
+SELECT * FROM drafts
+WHERE id = $1 AND status = 'scheduled' AND publish_at <= NOW()
+FOR UPDATE;
+
+-- name: MarkDraftPublished :exec
+UPDATE drafts
+SET status = 'published', chirp_id = $2, error = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkDraftFailed :exec
+UPDATE drafts
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: PostponeDraft :exec
+UPDATE drafts
+SET publish_at = $2, error = $3, updated_at = NOW()
+WHERE id = $1;
\ No newline at end of file
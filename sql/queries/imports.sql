@@ -0,0 +1,53 @@
+-- name: CreateImport :one
+INSERT INTO imports(id, created_at, updated_at, user_id, source, long_body_policy, total)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetImportByID :one
+SELECT * FROM imports
+WHERE id = $1;
+
+-- name: UpdateImportProgress :exec
+UPDATE imports
+SET processed = $2, imported = $3, skipped = $4, failed = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetImportStatus :exec
+UPDATE imports
+SET status = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateImportError :exec
+INSERT INTO import_errors(id, created_at, import_id, item_ref, message)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+);
+
+-- name: GetImportErrors :many
+SELECT * FROM import_errors
+WHERE import_id = $1
+ORDER BY created_at;
+
+-- name: CreateImportItems :exec
+INSERT INTO import_items(import_id, items)
+VALUES($1, $2);
+
+-- name: GetImportItems :one
+SELECT items FROM import_items
+WHERE import_id = $1;
+
+-- name: DeleteImportItems :exec
+DELETE FROM import_items
+WHERE import_id = $1;
\ No newline at end of file
@@ -0,0 +1,57 @@
+-- name: EnqueueJob :execrows
+INSERT INTO jobs(id, created_at, updated_at, kind, payload, unique_key, max_attempts, run_at)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (kind, unique_key) DO NOTHING;
+
+-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_until = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::int), updated_at = NOW()
+WHERE id = (
+    SELECT id FROM jobs
+    WHERE kind = ANY(sqlc.arg(kinds)::text[])
+    AND ((status = 'queued' AND run_at <= NOW()) OR (status = 'running' AND locked_until < NOW()))
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'done', locked_until = NULL, last_error = '', finished_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'queued', locked_until = NULL, run_at = $2, last_error = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: KillJob :exec
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = $2, finished_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RequeueDeadJob :one
+UPDATE jobs
+SET status = 'queued', attempts = 0, run_at = NOW(), last_error = '', finished_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'dead'
+RETURNING *;
+
+-- name: GetRecentJobs :many
+SELECT * FROM jobs
+WHERE sqlc.arg(status)::text = '' OR status = sqlc.arg(status)::text
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs
+WHERE (status = 'done' AND finished_at < $1) OR (status = 'dead' AND finished_at < $2);
\ No newline at end of file
@@ -0,0 +1,29 @@
+-- name: QueueLinkPreview :exec
+INSERT INTO link_previews(url, created_at, updated_at)
+VALUES(
+    $1,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (url) DO NOTHING;
+
+-- name: ClaimLinkPreview :one
+UPDATE link_previews
+SET status = 'fetching', attempts = attempts + 1, updated_at = NOW()
+WHERE url = $1
+AND (status = 'pending' OR (status = 'fetching' AND updated_at < $2 AND attempts < 3))
+RETURNING *;
+
+-- name: SetLinkPreviewReady :exec
+UPDATE link_previews
+SET status = 'ready', title = $2, description = $3, image_url = $4, site_name = $5, error = NULL, updated_at = NOW()
+WHERE url = $1;
+
+-- name: SetLinkPreviewFailed :exec
+UPDATE link_previews
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE url = $1;
+
+-- name: GetReadyLinkPreviews :many
+SELECT * FROM link_previews
+WHERE url = ANY(sqlc.arg(urls)::text[]) AND status = 'ready';
\ No newline at end of file
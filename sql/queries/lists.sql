@@ -0,0 +1,68 @@
+-- name: CreateList :one
+INSERT INTO lists(id, created_at, updated_at, user_id, name, description, is_private)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetListByID :one
+SELECT lists.* FROM lists
+JOIN users ON users.id = lists.user_id
+WHERE lists.id = $1 AND users.deleted_at IS NULL;
+
+-- name: GetListsForUser :many
+SELECT * FROM lists
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: CountListsForUser :one
+SELECT COUNT(*) FROM lists
+WHERE user_id = $1;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $3, description = $4, is_private = $5, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1 AND user_id = $2;
+
+-- name: AddListMember :execrows
+INSERT INTO list_members(list_id, user_id, created_at)
+VALUES(
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :exec
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members
+WHERE list_id = $1;
+
+-- name: GetListMembers :many
+SELECT list_members.* FROM list_members
+JOIN users ON users.id = list_members.user_id
+WHERE list_members.list_id = $1 AND users.deleted_at IS NULL
+ORDER BY list_members.created_at ASC;
+
+-- name: GetListChirps :many
+SELECT chirps.* FROM chirps
+JOIN list_members ON list_members.user_id = chirps.user_id
+JOIN users ON users.id = chirps.user_id
+WHERE list_members.list_id = sqlc.arg(list_id) AND users.deleted_at IS NULL
+AND (chirps.created_at, chirps.id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
\ No newline at end of file
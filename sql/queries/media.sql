@@ -0,0 +1,45 @@
+-- name: CreateMedia :one
+INSERT INTO media(id, created_at, user_id, content_type, size_bytes, width, height, alt_text, storage_key, thumbnail_key)
+VALUES(
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING *;
+
+-- name: GetMediaByID :one
+SELECT * FROM media
+WHERE id = $1;
+
+-- name: UpdateMediaAltText :one
+UPDATE media
+SET alt_text = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: AttachMediaToChirp :many
+UPDATE media
+SET chirp_id = sqlc.arg(chirp_id)::uuid, position = array_position(sqlc.arg(media_ids)::uuid[], id)
+WHERE id = ANY(sqlc.arg(media_ids)::uuid[]) AND user_id = sqlc.arg(user_id)::uuid AND chirp_id IS NULL
+RETURNING *;
+
+-- name: GetMediaForChirps :many
+SELECT * FROM media
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY position;
+
+-- name: GetOrphanedMedia :many
+SELECT * FROM media
+WHERE chirp_id IS NULL AND (created_at < $1 OR user_id IS NULL)
+AND NOT EXISTS (SELECT 1 FROM users WHERE users.avatar_media_id = media.id);
+
+-- name: DeleteMediaByID :exec
+DELETE FROM media
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,51 @@
+-- name: MuteUser :exec
+INSERT INTO mutes(muter_id, muted_id, created_at, expires_at)
+VALUES(
+    $1,
+    $2,
+    NOW(),
+    $3
+)
+ON CONFLICT (muter_id, muted_id) DO UPDATE SET expires_at = EXCLUDED.expires_at;
+
+-- name: UnmuteUser :exec
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutedAmong :many
+SELECT muted_id FROM mutes
+WHERE muter_id = sqlc.arg(muter_id) AND muted_id = ANY(sqlc.arg(muted_ids)::uuid[])
+AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: GetMutesForUser :many
+SELECT * FROM mutes
+WHERE muter_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC;
+
+-- name: CreateKeywordMute :one
+INSERT INTO keyword_mutes(id, created_at, user_id, keyword, expires_at)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetKeywordMutesForUser :many
+SELECT * FROM keyword_mutes
+WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC;
+
+-- name: DeleteKeywordMute :execrows
+DELETE FROM keyword_mutes
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteExpiredMutes :execrows
+DELETE FROM mutes
+WHERE expires_at <= NOW();
+
+-- name: DeleteExpiredKeywordMutes :execrows
+DELETE FROM keyword_mutes
+WHERE expires_at <= NOW();
\ No newline at end of file
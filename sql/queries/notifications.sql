@@ -0,0 +1,68 @@
+-- name: UpsertNotification :one
+INSERT INTO notifications(id, created_at, updated_at, user_id, type, group_key, chirp_id)
+SELECT
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.arg(type),
+    sqlc.arg(group_key),
+    sqlc.arg(chirp_id)
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE user_id = sqlc.arg(user_id) AND type = sqlc.arg(type) AND NOT enabled
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL DO UPDATE SET updated_at = NOW()
+RETURNING *;
+
+-- name: AddNotificationActor :exec
+INSERT INTO notification_actors(notification_id, actor_id, created_at)
+VALUES(
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: GetNotificationByID :one
+SELECT * FROM notifications
+WHERE id = $1;
+
+-- name: GetNotificationsPage :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+AND (updated_at, id) < (sqlc.arg(before_updated_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY updated_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetNotificationActors :many
+SELECT notification_id, actor_id FROM notification_actors
+WHERE notification_id = ANY(sqlc.arg(notification_ids)::uuid[])
+ORDER BY created_at DESC;
+
+-- name: GetUnreadNotificationCount :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::uuid[]) AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences(user_id, type, enabled)
+VALUES(
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
\ No newline at end of file
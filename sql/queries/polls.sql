@@ -0,0 +1,50 @@
+-- name: CreatePoll :one
+INSERT INTO polls(id, created_at, chirp_id, expires_at)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options(id, poll_id, position, text)
+VALUES(
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+);
+
+-- name: GetPollByChirpID :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollsForChirps :many
+SELECT * FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollOptionsForPolls :many
+SELECT * FROM poll_options
+WHERE poll_id = ANY(sqlc.arg(poll_ids)::uuid[])
+ORDER BY position;
+
+-- name: GetPollVotesByUser :many
+SELECT * FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND poll_id = ANY(sqlc.arg(poll_ids)::uuid[]);
+
+-- name: CastPollVote :one
+WITH vote AS (
+    INSERT INTO poll_votes(poll_id, user_id, option_id, created_at)
+    SELECT poll_options.poll_id, sqlc.arg(user_id)::uuid, poll_options.id, NOW()
+    FROM poll_options
+    JOIN polls ON polls.id = poll_options.poll_id
+    WHERE poll_options.id = sqlc.arg(option_id) AND poll_options.poll_id = sqlc.arg(poll_id) AND polls.expires_at > NOW()
+    ON CONFLICT (poll_id, user_id) DO NOTHING
+    RETURNING option_id
+)
+UPDATE poll_options
+SET votes = votes + 1
+WHERE id IN (SELECT option_id FROM vote)
+RETURNING poll_id;
\ No newline at end of file
@@ -31,4 +31,9 @@ WHERE user_id = $1;
 -- name: RevokeSessionByID :exec
 UPDATE session_ids
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetSessionsForUser :many
+SELECT * FROM session_ids
+WHERE user_id = $1
+ORDER BY created_at;
\ No newline at end of file
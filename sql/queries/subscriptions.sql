@@ -0,0 +1,53 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions(id, created_at, updated_at, user_id, plan, provider, provider_ref, status, current_period_start, current_period_end)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (provider, provider_ref) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetSubscriptionByProviderRef :one
+SELECT * FROM subscriptions
+WHERE provider = $1 AND provider_ref = $2;
+
+-- name: UpdateSubscriptionStatus :one
+UPDATE subscriptions
+SET status = $2, current_period_end = $3, canceled_at = $4, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetCurrentSubscriptionForUser :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+ORDER BY updated_at DESC
+LIMIT 1;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events(id, created_at, subscription_id, event, status)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+);
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status IN ('active', 'canceled') AND current_period_end <= NOW()
+RETURNING *;
\ No newline at end of file
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users(id, created_at, updated_at, email, hashed_password)
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, handle)
 VALUES(
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -17,6 +18,14 @@ WHERE email = $1;
 SELECT * FROM users
 WHERE id = $1;
 
+-- name: LockUserByID :one
+SELECT * FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListUserPasswordHashes :many
+SELECT hashed_password FROM users;
+
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
 
@@ -24,6 +33,20 @@ DELETE FROM users;
 DELETE FROM users
 WHERE id = $1;
 
+-- name: DeactivateUser :exec
+UPDATE users
+SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RestoreUser :exec
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: PurgeDeactivatedUsers :execrows
+DELETE FROM users
+WHERE deleted_at IS NOT NULL AND deleted_at < $1;
+
 -- name: UpdateUserPassword :exec
 UPDATE users
 SET hashed_password = $2, updated_at = Now()
@@ -34,8 +57,26 @@ UPDATE users
 SET email = $2, updated_at = Now()
 WHERE id = $1;
 
--- name: UpdateUserVIP :one
+-- name: SyncUserChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'canceled')
+    AND (subscriptions.current_period_end IS NULL OR subscriptions.current_period_end > NOW())
+), updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE lower(handle) = lower($1);
+
+-- name: GetUsersByIDs :many
+SELECT * FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = TRUE, updated_at = Now()
+SET handle = $2, display_name = $3, bio = $4, avatar_media_id = $5, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
\ No newline at end of file
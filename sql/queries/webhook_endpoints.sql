@@ -0,0 +1,100 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints(id, created_at, updated_at, user_id, url, secret, event_types, description)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetWebhookEndpointByID :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: GetWebhookEndpointsForUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: CountWebhookEndpointsForUser :one
+SELECT COUNT(*) FROM webhook_endpoints
+WHERE user_id = $1;
+
+-- name: GetActiveWebhookEndpointsForEvent :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = sqlc.arg(user_id) AND disabled_at IS NULL AND sqlc.arg(event_type)::text = ANY(event_types);
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET url = $3, event_types = $4, description = $5, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: EnableWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET disabled_at = NULL, consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0
+WHERE id = $1;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    disabled_at = CASE WHEN consecutive_failures + 1 >= sqlc.arg(disable_after)::int THEN NOW() ELSE disabled_at END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries(id, created_at, updated_at, endpoint_id, event_type, payload, status, next_attempt_at)
+VALUES(
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4,
+    'pending',
+    NOW()
+)
+RETURNING *;
+
+-- name: ClaimWebhookDelivery :one
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::int), updated_at = NOW()
+WHERE webhook_deliveries.id = sqlc.arg(id)
+AND webhook_deliveries.status = 'pending' AND webhook_deliveries.next_attempt_at <= NOW()
+AND EXISTS (
+    SELECT 1 FROM webhook_endpoints
+    WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id AND webhook_endpoints.disabled_at IS NULL
+)
+RETURNING *;
+
+-- name: GetPendingWebhookDeliveryIDs :many
+SELECT id FROM webhook_deliveries
+WHERE endpoint_id = $1 AND status = 'pending';
+
+-- name: FinishWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt_at = $3, response_status = $4, last_error = $5,
+    delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() ELSE NULL END,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeliveriesForEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
\ No newline at end of file
@@ -0,0 +1,41 @@
+-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events(id, created_at, updated_at, provider, event_id, event_type, payload, status)
+VALUES(
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    'received'
+)
+ON CONFLICT (provider, event_id) DO UPDATE
+SET deliveries = webhook_events.deliveries + 1, updated_at = NOW()
+RETURNING *;
+
+-- name: ClaimWebhookEvent :execrows
+UPDATE webhook_events
+SET status = 'processing', locked_until = NOW() + make_interval(secs => $2::int), updated_at = NOW()
+WHERE id = $1
+AND (status IN ('received', 'failed') OR (status = 'processing' AND locked_until < NOW()));
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events
+SET status = $2, last_error = $3, locked_until = NULL, processed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookEventByID :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: GetRecentWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE sqlc.arg(status)::text = '' OR status = sqlc.arg(status)::text
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetFailedWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE status = 'failed'
+ORDER BY created_at;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE account_events(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE account_events;
\ No newline at end of file
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE imports(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    long_body_policy TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'running',
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    imported INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE import_errors(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    import_id UUID NOT NULL REFERENCES imports(id) ON DELETE CASCADE,
+    item_ref TEXT NOT NULL,
+    message TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE import_errors;
+DROP TABLE imports;
\ No newline at end of file
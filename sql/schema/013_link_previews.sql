@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN preview_url TEXT;
+
+CREATE TABLE link_previews(
+    url TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX link_previews_status_idx ON link_previews(status, created_at);
+
+-- +goose Down
+DROP TABLE link_previews;
+
+ALTER TABLE chirps
+DROP COLUMN preview_url;
\ No newline at end of file
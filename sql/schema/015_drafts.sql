@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE drafts(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    publish_at TIMESTAMP,
+    status TEXT NOT NULL DEFAULT 'draft',
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    error TEXT
+);
+
+CREATE INDEX drafts_due_idx ON drafts(publish_at) WHERE status = 'scheduled';
+
+-- +goose Down
+DROP TABLE drafts;
\ No newline at end of file
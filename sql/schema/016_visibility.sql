@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+
+CREATE TABLE follows(
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(follower_id, followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows(followee_id);
+
+CREATE TABLE chirp_mentions(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY(chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions(user_id);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+DROP TABLE follows;
+
+ALTER TABLE chirps
+DROP COLUMN visibility;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE mutes(
+    muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP,
+    PRIMARY KEY(muter_id, muted_id)
+);
+
+CREATE TABLE keyword_mutes(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    keyword TEXT NOT NULL,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX keyword_mutes_user_id_idx ON keyword_mutes(user_id);
+
+-- +goose Down
+DROP TABLE keyword_mutes;
+DROP TABLE mutes;
\ No newline at end of file
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT;
+
+UPDATE users
+SET handle = 'user_' || substr(replace(id::text, '-', ''), 1, 12);
+
+ALTER TABLE users
+ALTER COLUMN handle SET NOT NULL;
+
+CREATE UNIQUE INDEX users_handle_idx ON users(lower(handle));
+
+ALTER TABLE users
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_media_id UUID REFERENCES media(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN avatar_media_id,
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
\ No newline at end of file
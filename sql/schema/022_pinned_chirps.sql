@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN pin_position INTEGER;
+
+CREATE INDEX chirps_pinned_idx ON chirps(user_id, pin_position DESC) WHERE pin_position IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_pinned_idx;
+
+ALTER TABLE chirps
+DROP COLUMN pin_position;
\ No newline at end of file
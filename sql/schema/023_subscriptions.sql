@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    provider_ref TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP,
+    canceled_at TIMESTAMP,
+    UNIQUE(provider, provider_ref)
+);
+
+CREATE INDEX subscriptions_user_id_idx ON subscriptions(user_id);
+
+CREATE TABLE subscription_events(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    status TEXT NOT NULL
+);
+
+CREATE INDEX subscription_events_subscription_id_idx ON subscription_events(subscription_id, created_at);
+
+-- Upgrades used to only set the flag, so they become open ended subscriptions.
+INSERT INTO subscriptions(id, created_at, updated_at, user_id, plan, provider, provider_ref, status, current_period_start)
+SELECT gen_random_uuid(), updated_at, updated_at, id, 'chirpy_red', 'polka', id::text, 'active', updated_at
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscription_events;
+DROP TABLE subscriptions;
\ No newline at end of file
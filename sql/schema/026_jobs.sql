@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE jobs(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    unique_key TEXT,
+    status TEXT NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    finished_at TIMESTAMP,
+    UNIQUE(kind, unique_key)
+);
+
+CREATE INDEX jobs_due_idx ON jobs(run_at) WHERE status = 'queued';
+CREATE INDEX jobs_locked_idx ON jobs(locked_until) WHERE status = 'running';
+
+-- +goose Down
+DROP TABLE jobs;
\ No newline at end of file
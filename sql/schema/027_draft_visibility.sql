@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE drafts
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+
+-- +goose Down
+ALTER TABLE drafts
+DROP COLUMN visibility;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE webhook_events
+ADD COLUMN locked_until TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE webhook_events
+DROP COLUMN locked_until;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE import_items(
+    import_id UUID PRIMARY KEY REFERENCES imports(id) ON DELETE CASCADE,
+    items TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE import_items;
\ No newline at end of file
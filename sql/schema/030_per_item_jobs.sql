@@ -0,0 +1,26 @@
+-- +goose Up
+-- Link previews, scheduled drafts and webhook deliveries get a job each
+-- instead of being polled. Work that was waiting for the poller gets its job
+-- here.
+INSERT INTO jobs(id, created_at, updated_at, kind, payload, unique_key, max_attempts, run_at)
+SELECT gen_random_uuid(), NOW(), NOW(), 'fetch_link_preview', json_build_object('url', url)::text, url, 5, NOW()
+FROM link_previews
+WHERE status IN ('pending', 'fetching')
+ON CONFLICT (kind, unique_key) DO NOTHING;
+
+INSERT INTO jobs(id, created_at, updated_at, kind, payload, max_attempts, run_at)
+SELECT gen_random_uuid(), NOW(), NOW(), 'publish_draft', json_build_object('draft_id', id)::text, 5, publish_at
+FROM drafts
+WHERE status = 'scheduled';
+
+INSERT INTO jobs(id, created_at, updated_at, kind, payload, max_attempts, run_at)
+SELECT gen_random_uuid(), NOW(), NOW(), 'deliver_webhook', json_build_object('delivery_id', id)::text, 5, next_attempt_at
+FROM webhook_deliveries
+WHERE status = 'pending';
+
+DELETE FROM jobs
+WHERE kind IN ('fetch_link_previews', 'publish_scheduled_drafts', 'deliver_webhooks') AND status = 'queued';
+
+-- +goose Down
+DELETE FROM jobs
+WHERE kind IN ('fetch_link_preview', 'publish_draft', 'deliver_webhook');
\ No newline at end of file
@@ -0,0 +1,49 @@
+// Minimal htmx extension for Server-Sent Events.
+//
+// An element with sse-connect="<url>" opens an EventSource. Each element
+// inside it with sse-swap="<event>[,<event>...]" swaps the event data into
+// itself using its hx-swap style. The browser reconnects on its own and
+// sends the last event id, so missed events are replayed by the server.
+(function () {
+    function listen(source, target) {
+        target.getAttribute("sse-swap").split(",").forEach(function (name) {
+            source.addEventListener(name.trim(), function (event) {
+                htmx.swap(target, event.data, {
+                    swapStyle: target.getAttribute("hx-swap") || "innerHTML",
+                });
+            });
+        });
+    }
+
+    function connect(elt) {
+        var url = elt.getAttribute("sse-connect");
+        if (!url || elt.sseSource) {
+            return;
+        }
+
+        var source = new EventSource(url);
+        elt.sseSource = source;
+
+        if (elt.hasAttribute("sse-swap")) {
+            listen(source, elt);
+        }
+        elt.querySelectorAll("[sse-swap]").forEach(function (target) {
+            listen(source, target);
+        });
+    }
+
+    htmx.defineExtension("sse", {
+        onEvent: function (name, event) {
+            var elt = event.target || event.detail.elt;
+
+            if (name === "htmx:afterProcessNode" && elt.hasAttribute && elt.hasAttribute("sse-connect")) {
+                connect(elt);
+            }
+
+            if (name === "htmx:beforeCleanupElement" && elt.sseSource) {
+                elt.sseSource.close();
+                delete elt.sseSource;
+            }
+        },
+    });
+})();